@@ -6,8 +6,10 @@ import (
 	"github.com/spf13/cobra"
 )
 var (
-	isDev   bool
-	useGrep bool
+	isDev      bool
+	useGrep    bool
+	saveExact  bool
+	savePrefix string
 )
 var addCmd = &cobra.Command{
 	Use:   "add [packages...]",
@@ -15,7 +17,10 @@ var addCmd = &cobra.Command{
 	Long: `Add packages to your project and install them.
 Examples:
   tidy add react react-dom      # Add production dependencies
+  tidy add react@18             # Add a version range
+  tidy add typescript@next      # Add the version behind a dist-tag
   tidy add -D typescript        # Add dev dependency
+  tidy add -E lodash            # Save the exact version
   tidy add -g                   # Scan codebase and add found packages`,
 	Aliases: []string{"a"},
 	Run: func(cmd *cobra.Command, args []string) {
@@ -34,6 +39,13 @@ func init() {
 	rootCmd.AddCommand(addCmd)
 	addCmd.Flags().BoolVarP(&isDev, "dev", "D", false, "add as dev dependency")
 	addCmd.Flags().BoolVarP(&useGrep, "grep", "g", false, "scan codebase and add found packages")
+	addSaveFlags(addCmd)
+	addPlatformFlags(addCmd)
+	addMirrorFlag(addCmd)
+}
+func addSaveFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVarP(&saveExact, "save-exact", "E", false, "save exact versions instead of ranges (overrides save-exact in .npmrc)")
+	cmd.Flags().StringVar(&savePrefix, "save-prefix", "", "range prefix to save versions with, ^, ~ or empty (default save-prefix from .npmrc, else ^)")
 }
 func scanAndAddPackages() {
 	wd, err := os.Getwd()
@@ -82,19 +94,49 @@ func addSpecificPackages(packages []string) {
 	depType := "production"
 	if isDev {
 		depType = "development"
-		for _, pkg := range packages {
-			jsn.DevDependencies[pkg] = "latest"
-		}
-	} else {
-		for _, pkg := range packages {
-			jsn.Dependencies[pkg] = "latest"
-		}
 	}
 	fmt.Printf("Adding %d %s package(s)...\n", len(packages), depType)
+	addToPackageJson(&jsn, packages, isDev)
 	resolved, err := internal.Resolve(jsn)
 	if err != nil {
 		fmt.Printf("Error resolving dependencies: %v\n", err)
 		os.Exit(1)
 	}
+	if err := internal.WritePackageJson(wd, jsn); err != nil {
+		fmt.Printf("Error writing package.json: %v\n", err)
+		os.Exit(1)
+	}
 	installPackages(resolved)
+}
+// addToPackageJson resolves each name@spec argument and records the range to
+// save for it, moving it between dependencies and devDependencies if needed.
+func addToPackageJson(jsn *internal.PackageJson, packages []string, dev bool) {
+	prefix := internal.SavePrefix()
+	if prefix != "^" && prefix != "~" && prefix != "" {
+		fmt.Printf("Error: invalid save-prefix %q, use ^, ~ or an empty string\n", prefix)
+		os.Exit(1)
+	}
+	if jsn.Dependencies == nil {
+		jsn.Dependencies = make(map[string]string)
+	}
+	if jsn.DevDependencies == nil {
+		jsn.DevDependencies = make(map[string]string)
+	}
+	for _, arg := range packages {
+		name, spec, err := internal.SaveSpec(arg, prefix)
+		if err != nil {
+			fmt.Printf("Error resolving %s: %v\n", arg, err)
+			os.Exit(1)
+		}
+		if dev {
+			delete(jsn.Dependencies, name)
+			jsn.DevDependencies[name] = spec
+		} else {
+			delete(jsn.DevDependencies, name)
+			jsn.Dependencies[name] = spec
+		}
+		if IsVerbose() {
+			fmt.Printf("➕ %s@%s\n", name, spec)
+		}
+	}
 }
\ No newline at end of file
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/chann44/tidy/internal"
+	"github.com/spf13/cobra"
+)
+
+var ciCmd = &cobra.Command{
+	Use:   "ci",
+	Short: "Clean install exactly what tidy.lock records",
+	Long: `Remove node_modules and install exactly the packages recorded in tidy.lock.
+Fails without installing anything if tidy.lock is missing or does not match package.json.
+Examples:
+  tidy ci                   # Reproducible install for CI and fresh checkouts
+  tidy ci --mirror          # Same, without a registry, from the mirror written by tidy fetch`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cleanInstall()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(ciCmd)
+	addPlatformFlags(ciCmd)
+	addMirrorFlag(ciCmd)
+}
+func cleanInstall() {
+	wd, err := os.Getwd()
+	if err != nil {
+		fmt.Printf("Error getting working directory: %v\n", err)
+		os.Exit(1)
+	}
+	if _, err := os.Stat("package.json"); os.IsNotExist(err) {
+		fmt.Println("❌ No package.json found")
+		os.Exit(1)
+	}
+	jsn, err := internal.ReadJson(wd)
+	if err != nil {
+		fmt.Printf("Error reading package.json: %v\n", err)
+		os.Exit(1)
+	}
+	resolved, err := internal.ResolveFrozen(wd, jsn)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+	if !IsQuiet() {
+		fmt.Println("🧹 Removing node_modules...")
+	}
+	if err := os.RemoveAll(filepath.Join(wd, "node_modules")); err != nil {
+		fmt.Printf("Error removing node_modules: %v\n", err)
+		os.Exit(1)
+	}
+	installPackages(resolved)
+}
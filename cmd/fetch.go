@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/chann44/tidy/internal"
+	"github.com/spf13/cobra"
+)
+
+var mirrorOutput string
+var fetchCmd = &cobra.Command{
+	Use:   "fetch",
+	Short: "Download every locked package into a project-local mirror",
+	Long: `Download the tarball of every package in the resolved graph, along with its
+registry metadata, into a mirror directory. Commit or ship the mirror and
+install with --mirror to build without a registry.
+Examples:
+  tidy fetch                    # Write the mirror to .tidy/mirror
+  tidy fetch --dir vendor/npm   # Write it somewhere else
+  tidy ci --mirror              # Install from .tidy/mirror only`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fetchMirror()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(fetchCmd)
+	fetchCmd.Flags().StringVar(&mirrorOutput, "dir", internal.MirrorDirName, "directory to write the mirror to")
+	fetchCmd.Flags().BoolVar(&frozenLockfile, "frozen-lockfile", false, "fail if tidy.lock is missing or out of date")
+}
+func fetchMirror() {
+	wd, err := os.Getwd()
+	if err != nil {
+		fmt.Printf("Error getting working directory: %v\n", err)
+		os.Exit(1)
+	}
+	if _, err := os.Stat("package.json"); os.IsNotExist(err) {
+		fmt.Println("❌ No package.json found")
+		os.Exit(1)
+	}
+	jsn, err := internal.ReadJson(wd)
+	if err != nil {
+		fmt.Printf("Error reading package.json: %v\n", err)
+		os.Exit(1)
+	}
+	var resolved internal.Resolved
+	if frozenLockfile {
+		resolved, err = internal.ResolveFrozen(wd, jsn)
+	} else {
+		resolved, err = internal.Resolve(jsn)
+	}
+	if err != nil {
+		fmt.Printf("Error resolving dependencies: %v\n", err)
+		os.Exit(1)
+	}
+	printImportWarnings()
+	if !IsQuiet() {
+		fmt.Printf("📥 Mirroring %d package(s) into %s...\n", len(resolved.Packages), mirrorOutput)
+	}
+	fetched, skipped, err := internal.FetchMirror(resolved, mirrorOutput)
+	if IsVerbose() {
+		for _, key := range fetched {
+			fmt.Printf("✓ Fetched %s\n", key)
+		}
+	}
+	for _, key := range skipped {
+		fmt.Printf("⚠️  Warning: %s is a git dependency and cannot be mirrored\n", key)
+	}
+	printRetrySummary()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ Mirror is up to date (%d new tarball(s))\n", len(fetched))
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/chann44/tidy/internal"
+	"github.com/spf13/cobra"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Create tidy.lock from another package manager's lockfile",
+	Long: `Create tidy.lock from an existing package-lock.json, yarn.lock or
+pnpm-lock.yaml, reusing its exact versions and tarball URLs instead of
+resolving them again.
+Examples:
+  tidy import               # Convert the project's lockfile into tidy.lock`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		importLockfile()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+}
+func importLockfile() {
+	wd, err := os.Getwd()
+	if err != nil {
+		fmt.Printf("Error getting working directory: %v\n", err)
+		os.Exit(1)
+	}
+	if _, err := os.Stat("package.json"); os.IsNotExist(err) {
+		fmt.Println("❌ No package.json found")
+		os.Exit(1)
+	}
+	jsn, err := internal.ReadJson(wd)
+	if err != nil {
+		fmt.Printf("Error reading package.json: %v\n", err)
+		os.Exit(1)
+	}
+	resolved, source, err := internal.Import(wd, jsn)
+	if err != nil {
+		fmt.Printf("❌ Import failed: %v\n", err)
+		os.Exit(1)
+	}
+	printImportWarnings()
+	fmt.Printf("✅ Imported %d package(s) from %s into %s\n", len(resolved.Packages), source, internal.LockfileName)
+}
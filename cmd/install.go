@@ -10,9 +10,13 @@ import (
 )
 
 var (
-	useBun  bool
-	usePnpm bool
-	useNpm  bool
+	useBun         bool
+	usePnpm        bool
+	useNpm         bool
+	frozenLockfile bool
+	targetOS       string
+	targetCPU      string
+	targetLibc     string
 )
 var installCmd = &cobra.Command{
 	Use:   "install [packages...]",
@@ -21,9 +25,13 @@ var installCmd = &cobra.Command{
 Examples:
   btidy install              # Install all dependencies from package.json
   btidy install react        # Install react
+  btidy install react@18     # Install a version range or dist-tag
   btidy install --bun        # Install using Bun
   btidy install --pnpm       # Install using pnpm
-  btidy install --npm        # Install using npm`,
+  btidy install --npm        # Install using npm
+  btidy install --frozen-lockfile  # Install exactly what tidy.lock records
+  btidy install --os linux --cpu arm64  # Install for another platform, e.g. a Docker image
+  btidy install --mirror     # Install from the mirror written by btidy fetch`,
 	Aliases: []string{"i"},
 	Run: func(cmd *cobra.Command, args []string) {
 		pm := getPackageManager()
@@ -40,6 +48,39 @@ func init() {
 	installCmd.Flags().BoolVar(&useBun, "bun", false, "use Bun package manager")
 	installCmd.Flags().BoolVar(&usePnpm, "pnpm", false, "use pnpm package manager")
 	installCmd.Flags().BoolVar(&useNpm, "npm", false, "use npm package manager")
+	installCmd.Flags().BoolVar(&frozenLockfile, "frozen-lockfile", false, "fail if tidy.lock is missing or out of date")
+	addSaveFlags(installCmd)
+	addPlatformFlags(installCmd)
+	addMirrorFlag(installCmd)
+}
+func addPlatformFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&targetOS, "os", "", "install optional dependencies for this OS instead of the host's (linux, darwin, win32...)")
+	cmd.Flags().StringVar(&targetCPU, "cpu", "", "install optional dependencies for this CPU instead of the host's (x64, arm64...)")
+	cmd.Flags().StringVar(&targetLibc, "libc", "", "install optional dependencies for this libc on Linux (glibc or musl)")
+}
+// addMirrorFlag lets an install take every package from a mirror written by
+// tidy fetch instead of the registry.
+func addMirrorFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&mirror, "mirror", "", "install only from the mirror written by tidy fetch (default "+internal.MirrorDirName+")")
+	cmd.Flags().Lookup("mirror").NoOptDefVal = internal.MirrorDirName
+}
+// targetPlatform is the host platform with the --os, --cpu and --libc flags
+// applied on top.
+func targetPlatform() internal.Platform {
+	platform := internal.HostPlatform()
+	if targetOS != "" && targetOS != platform.OS {
+		platform.OS, platform.Libc = targetOS, ""
+		if targetOS == "linux" {
+			platform.Libc = "glibc"
+		}
+	}
+	if targetCPU != "" {
+		platform.CPU = targetCPU
+	}
+	if targetLibc != "" {
+		platform.Libc = targetLibc
+	}
+	return platform
 }
 func getPackageManager() string {
 	if useBun {
@@ -78,17 +119,16 @@ func installSpecificPackages(pm string, packages []string) {
 	} else {
 		jsn, _ = internal.ReadJson(wd)
 	}
-	if jsn.Dependencies == nil {
-		jsn.Dependencies = make(map[string]string)
-	}
-	for _, pkg := range packages {
-		jsn.Dependencies[pkg] = "latest"
-	}
+	addToPackageJson(&jsn, packages, false)
 	resolved, err := internal.Resolve(jsn)
 	if err != nil {
 		fmt.Printf("Error resolving dependencies: %v\n", err)
 		os.Exit(1)
 	}
+	if err := internal.WritePackageJson(wd, jsn); err != nil {
+		fmt.Printf("Error writing package.json: %v\n", err)
+		os.Exit(1)
+	}
 	installPackages(resolved)
 }
 func installAllPackages() {
@@ -107,66 +147,107 @@ func installAllPackages() {
 		os.Exit(1)
 	}
 	fmt.Println("📦 Installing dependencies from package.json...")
-	resolved, err := internal.Resolve(jsn)
+	var resolved internal.Resolved
+	if frozenLockfile {
+		resolved, err = internal.ResolveFrozen(wd, jsn)
+	} else {
+		resolved, err = internal.Resolve(jsn)
+	}
 	if err != nil {
 		fmt.Printf("Error resolving dependencies: %v\n", err)
 		os.Exit(1)
 	}
 	installPackages(resolved)
 }
-func installPackages(resolved map[string]internal.Deps) {
-	fmt.Printf("Installing %d package(s)...\n\n", len(resolved))
-	const maxConcurrency = 50
-	semaphore := make(chan struct{}, maxConcurrency)
-	var wg sync.WaitGroup
-	var mu sync.Mutex
-	var errors []string
-	installing := make(map[string]bool)
-	var installingMu sync.Mutex
-	for name, deps := range resolved {
-		if internal.IsInstalled(name) {
-			if !IsQuiet() {
-				fmt.Printf("⏭️  Skipping %s@%s (already installed)\n", name, deps.Version)
-			}
-			continue
+func installPackages(resolved internal.Resolved) {
+	platform := targetPlatform()
+	resolved, skipped, err := internal.ForPlatform(resolved, platform)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+	if IsVerbose() {
+		for _, key := range skipped {
+			fmt.Printf("⏭️  Skipping optional %s (not supported on %s)\n", key, platform)
 		}
-		installingMu.Lock()
-		if installing[name] {
-			installingMu.Unlock()
-			if !IsQuiet() {
-				fmt.Printf("⏭️  Skipping %s@%s (installation in progress)\n", name, deps.Version)
+	}
+	layout := internal.Hoist(resolved)
+	if internal.Offline() {
+		var missing []string
+		checked := make(map[string]bool)
+		for _, level := range layout.ByDepth() {
+			for _, dir := range level {
+				deps := resolved.Packages[layout[dir]]
+				if checked[layout[dir]] || internal.IsInstalled(dir, deps) {
+					continue
+				}
+				checked[layout[dir]] = true
+				if !internal.InStore(deps) {
+					missing = append(missing, fmt.Sprintf("%s@%s (%s)", deps.Name, deps.Version, deps.Tarball))
+				}
 			}
-			continue
 		}
-		installing[name] = true
-		installingMu.Unlock()
-		wg.Add(1)
-		go func(name string, deps internal.Deps) {
-			defer wg.Done()
-			defer func() {
-				installingMu.Lock()
-				delete(installing, name)
-				installingMu.Unlock()
-			}()
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
-			if IsVerbose() {
-				fmt.Printf("📥 Installing %s@%s\n", name, deps.Version)
-			}
-			err := internal.Install(name, deps.Tarball)
-			if err != nil {
-				mu.Lock()
-				errors = append(errors, fmt.Sprintf("%s: %v", name, err))
-				mu.Unlock()
-				fmt.Printf("❌ Error installing %s: %v\n", name, err)
-				return
+		if len(missing) > 0 {
+			where := "the store"
+			if mirror != "" {
+				where = "the store or the mirror"
 			}
-			if !IsQuiet() {
-				fmt.Printf("✓ Installed %s@%s\n", name, deps.Version)
+			fmt.Printf("❌ %d package(s) are not in %s and cannot be installed offline:\n", len(missing), where)
+			for _, m := range missing {
+				fmt.Printf("  - %s\n", m)
 			}
-		}(name, deps)
+			os.Exit(1)
+		}
+	}
+	fmt.Printf("Installing %d package(s)...\n\n", len(layout))
+	printImportWarnings()
+	for _, warning := range internal.PeerWarnings(resolved) {
+		fmt.Printf("⚠️  Warning: %s\n", warning)
 	}
+	for _, warning := range internal.OptionalWarnings() {
+		fmt.Printf("⚠️  Warning: Skipping optional %s\n", warning)
+	}
+	const maxConcurrency = 50
+	semaphore := make(chan struct{}, maxConcurrency)
+	var mu sync.Mutex
+	var errors []string
+	for _, level := range layout.ByDepth() {
+		var wg sync.WaitGroup
+		for _, dir := range level {
+			deps := resolved.Packages[layout[dir]]
+			if internal.IsInstalled(dir, deps) {
+				if !IsQuiet() {
+					fmt.Printf("⏭️  Skipping %s@%s (already installed)\n", dir, deps.Version)
+				}
+				continue
+			}
+			wg.Add(1)
+			go func(dir string, deps internal.Deps) {
+				defer wg.Done()
+				semaphore <- struct{}{}
+				defer func() { <-semaphore }()
+				if IsVerbose() {
+					fmt.Printf("📥 Installing %s@%s\n", dir, deps.Version)
+				}
+				err := internal.Install(dir, deps)
+				if err != nil && deps.Optional {
+					fmt.Printf("⚠️  Warning: Skipping optional %s: %v\n", dir, err)
+					return
+				}
+				if err != nil {
+					mu.Lock()
+					errors = append(errors, fmt.Sprintf("%s: %v", dir, err))
+					mu.Unlock()
+					fmt.Printf("❌ Error installing %s: %v\n", dir, err)
+					return
+				}
+				if !IsQuiet() {
+					fmt.Printf("✓ Installed %s@%s\n", dir, deps.Version)
+				}
+			}(dir, deps)
+		}
 		wg.Wait()
+	}
 		fmt.Println()
 		
 		if !IsQuiet() {
@@ -175,6 +256,7 @@ func installPackages(resolved map[string]internal.Deps) {
 		if err := internal.LinkBinaries(); err != nil {
 			fmt.Printf("⚠️  Warning: Failed to link binaries: %v\n", err)
 		}
+		printRetrySummary()
 		
 		if len(errors) > 0 {
 			fmt.Printf("⚠️  Completed with %d error(s):\n", len(errors))
@@ -186,3 +268,24 @@ func installPackages(resolved map[string]internal.Deps) {
 			fmt.Println("✅ All packages installed successfully!")
 		}
 }
+// printRetrySummary reports the registry requests that had to be retried,
+// listing them in verbose mode.
+func printRetrySummary() {
+	retries := internal.Retries()
+	if len(retries) == 0 || IsQuiet() {
+		return
+	}
+	fmt.Printf("🔁 Retried %d registry request(s)\n", len(retries))
+	if IsVerbose() {
+		for _, retry := range retries {
+			fmt.Printf("  - %s\n", retry)
+		}
+	}
+}
+// printImportWarnings reports lockfiles of other package managers that could
+// not seed the resolution.
+func printImportWarnings() {
+	for _, warning := range internal.ImportWarnings() {
+		fmt.Printf("⚠️  Warning: %s\n", warning)
+	}
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/chann44/tidy/internal"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportFormat string
+	exportOutput string
+)
+var lockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Work with tidy.lock",
+}
+var lockExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export tidy's resolution as another package manager's lockfile",
+	Long: `Export the resolved dependency graph in another package manager's format.
+Examples:
+  tidy lock export --format npm             # Write package-lock.json (v3)
+  tidy lock export --format npm -o out.json # Write to a custom path`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		exportLockfile()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(lockCmd)
+	lockCmd.AddCommand(lockExportCmd)
+	lockExportCmd.Flags().StringVar(&exportFormat, "format", "npm", "lockfile format to export (npm)")
+	lockExportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "output path (defaults to the format's standard file name)")
+}
+func exportLockfile() {
+	if exportFormat != "npm" {
+		fmt.Printf("❌ Unsupported lockfile format %q (supported: npm)\n", exportFormat)
+		os.Exit(1)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		fmt.Printf("Error getting working directory: %v\n", err)
+		os.Exit(1)
+	}
+	if _, err := os.Stat("package.json"); os.IsNotExist(err) {
+		fmt.Println("❌ No package.json found")
+		os.Exit(1)
+	}
+	jsn, err := internal.ReadJson(wd)
+	if err != nil {
+		fmt.Printf("Error reading package.json: %v\n", err)
+		os.Exit(1)
+	}
+	resolved, err := internal.Resolve(jsn)
+	if err != nil {
+		fmt.Printf("Error resolving dependencies: %v\n", err)
+		os.Exit(1)
+	}
+	printImportWarnings()
+	workspaces, err := internal.FindWorkspaces(wd, jsn)
+	if err != nil {
+		fmt.Printf("Error finding workspaces: %v\n", err)
+		os.Exit(1)
+	}
+	data, err := internal.ExportNpmLockfile(jsn, workspaces, resolved)
+	if err != nil {
+		fmt.Printf("Error exporting lockfile: %v\n", err)
+		os.Exit(1)
+	}
+	output := exportOutput
+	if output == "" {
+		output = filepath.Join(wd, internal.NpmLockfileName)
+	}
+	if err := os.WriteFile(output, data, 0644); err != nil {
+		fmt.Printf("Error writing %s: %v\n", output, err)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ Wrote %s\n", output)
+}
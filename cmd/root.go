@@ -3,7 +3,9 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 
+	"github.com/chann44/tidy/internal"
 	"github.com/chann44/tidy/ui"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/spf13/cobra"
@@ -12,8 +14,11 @@ import (
 const Version = "0.1.0"
 
 var (
-	verbose bool
-	quiet   bool
+	verbose       bool
+	quiet         bool
+	offline       bool
+	preferOffline bool
+	mirror        string
 )
 var rootCmd = &cobra.Command{
 	Use:   "btidy",
@@ -25,6 +30,28 @@ var rootCmd = &cobra.Command{
   • Support multiple package managers (Bun, pnpm, npm)
 When run without arguments, Tidy launches an interactive UI.`,
 	Version: Version,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if offline {
+			internal.SetConfig("offline", "true")
+		}
+		if preferOffline {
+			internal.SetConfig("prefer-offline", "true")
+		}
+		if cmd.Flags().Changed("save-exact") {
+			internal.SetConfig("save-exact", fmt.Sprint(saveExact))
+		}
+		if cmd.Flags().Changed("save-prefix") {
+			internal.SetConfig("save-prefix", savePrefix)
+		}
+		if mirror != "" {
+			dir, err := filepath.Abs(mirror)
+			if err != nil {
+				fmt.Printf("❌ %v\n", err)
+				os.Exit(1)
+			}
+			internal.SetConfig("mirror", dir)
+		}
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		p := tea.NewProgram(ui.NewModel(), tea.WithAltScreen())
 		if _, err := p.Run(); err != nil {
@@ -43,6 +70,8 @@ func Execute() {
 func init() {
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
 	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "quiet output")
+	rootCmd.PersistentFlags().BoolVar(&offline, "offline", false, "never touch the network, install only from the cache and store")
+	rootCmd.PersistentFlags().BoolVar(&preferOffline, "prefer-offline", false, "use cached metadata when it satisfies the range, going online only on a miss")
 }
 func IsVerbose() bool {
 	return verbose
@@ -0,0 +1,143 @@
+package internal
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// nerfDart is the "//host/path/" form .npmrc scopes credentials with.
+func nerfDart(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	dir := u.Path
+	if !strings.HasSuffix(dir, "/") {
+		dir = path.Dir(dir)
+	}
+	return "//" + u.Host + strings.TrimSuffix(dir, "/") + "/"
+}
+
+// credentials returns the Authorization header configured for prefix, a
+// nerf dart, or for the unscoped keys when prefix is empty.
+func (c npmConfig) credentials(prefix string) string {
+	field := func(name string) string {
+		if prefix == "" {
+			return c[name]
+		}
+		return c[prefix+":"+name]
+	}
+	if token := field("_authToken"); token != "" {
+		return "Bearer " + token
+	}
+	if auth := field("_auth"); auth != "" {
+		return "Basic " + auth
+	}
+	username, password := field("username"), field("_password")
+	if username == "" || password == "" {
+		return ""
+	}
+	decoded, err := base64.StdEncoding.DecodeString(password)
+	if err != nil {
+		return ""
+	}
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+string(decoded)))
+}
+
+// registryCredentials returns the credentials of a configured registry.
+// Unscoped _authToken, _auth and username/_password only ever belong to the
+// default registry.
+func (c npmConfig) registryCredentials(registry string) string {
+	dart := nerfDart(registry + "/")
+	if auth := c.credentials(dart); auth != "" {
+		return auth
+	}
+	if dart == nerfDart(registryFor("")+"/") {
+		return c.credentials("")
+	}
+	return ""
+}
+
+func (c npmConfig) alwaysAuth(registry string) bool {
+	if value, ok := c[nerfDart(registry+"/")+":always-auth"]; ok {
+		return value == "true"
+	}
+	return c["always-auth"] == "true"
+}
+
+// authorization returns the credentials to send with a request to rawURL:
+// those configured for the longest nerf dart the URL falls under. A registry
+// with always-auth also lends its credentials to other paths on its host,
+// where some registries serve tarballs. Credentials never go to another host.
+func authorization(rawURL string) string {
+	cfg := config()
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	registries := []string{registryFor("")}
+	for key, value := range cfg {
+		if strings.HasPrefix(key, "@") && strings.HasSuffix(key, ":registry") {
+			registries = append(registries, strings.TrimSuffix(value, "/"))
+		}
+	}
+	for dart := nerfDart(rawURL); strings.HasPrefix(dart, "//"+u.Host+"/"); dart = parentDart(dart) {
+		if auth := cfg.credentials(dart); auth != "" {
+			return auth
+		}
+		if dart == nerfDart(registries[0]+"/") {
+			if auth := cfg.credentials(""); auth != "" {
+				return auth
+			}
+		}
+	}
+	for _, registry := range registries {
+		if r, err := url.Parse(registry); err == nil && r.Host == u.Host && cfg.alwaysAuth(registry) {
+			if auth := cfg.registryCredentials(registry); auth != "" {
+				return auth
+			}
+		}
+	}
+	return ""
+}
+
+// parentDart drops the last path segment of a nerf dart; past "//host/" it
+// returns "" to end the walk.
+func parentDart(dart string) string {
+	trimmed := strings.TrimSuffix(dart, "/")
+	idx := strings.LastIndex(trimmed, "/")
+	if idx <= 1 {
+		return ""
+	}
+	return trimmed[:idx+1]
+}
+
+// registryRequest builds a GET for url carrying the credentials configured
+// for it.
+func registryRequest(url string) (*http.Request, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "tidy/1.0")
+	if auth := authorization(url); auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	return req, nil
+}
+
+// sameHostRedirect keeps the credentials of a request only while redirects
+// stay on its host; net/http would also forward them to subdomains.
+func sameHostRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if req.URL.Host != via[0].URL.Host {
+		req.Header.Del("Authorization")
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// useNpmrc runs the test with a fresh home whose .npmrc holds contents.
+func useNpmrc(t *testing.T, contents string) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("NPM_CONFIG_GLOBALCONFIG", filepath.Join(home, "npmrc"))
+	if err := os.WriteFile(filepath.Join(home, npmrcName), []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	npmrcOnce = sync.Once{}
+	cacheMu.Lock()
+	packumentCache = make(map[string]*Packument)
+	cacheMu.Unlock()
+	t.Cleanup(func() { npmrcOnce = sync.Once{} })
+}
+
+// recorder is a server remembering the Authorization header of every request.
+type recorder struct {
+	*httptest.Server
+	mu   sync.Mutex
+	auth map[string]string
+}
+
+func newRecorder(t *testing.T, handler func(w http.ResponseWriter, r *http.Request)) *recorder {
+	rec := &recorder{auth: make(map[string]string)}
+	rec.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec.mu.Lock()
+		rec.auth[r.URL.Path] = r.Header.Get("Authorization")
+		rec.mu.Unlock()
+		handler(w, r)
+	}))
+	t.Cleanup(rec.Close)
+	return rec
+}
+
+func (rec *recorder) authorization(path string) (string, bool) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	auth, ok := rec.auth[path]
+	return auth, ok
+}
+
+func TestRegistryAuth(t *testing.T) {
+	other := newRecorder(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	var registry *recorder
+	registry = newRecorder(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/npm/private":
+			w.Write([]byte(`{"name": "private", "versions": {"1.0.0": {"name": "private", "version": "1.0.0",
+				"dist": {"tarball": "` + registry.URL + `/npm/private/-/private-1.0.0.tgz"}}}}`))
+		case "/npm/private/-/private-1.0.0.tgz":
+			http.Redirect(w, r, other.URL+"/cdn/private-1.0.0.tgz", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	host := strings.TrimPrefix(registry.URL, "http://")
+	useNpmrc(t, "registry="+registry.URL+"/npm/\n//"+host+"/npm/:_authToken=s3cret\n")
+
+	t.Run("sent to the configured registry", func(t *testing.T) {
+		packument, err := FetchPackument("private")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := packument.Versions["1.0.0"]; !ok {
+			t.Fatalf("packument has no 1.0.0: %+v", packument)
+		}
+	})
+
+	t.Run("not sent to another host", func(t *testing.T) {
+		err := registryGet(other.URL+"/npm/private", nil, func(*http.Response) error { return nil })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if auth, ok := other.authorization("/npm/private"); !ok || auth != "" {
+			t.Errorf("other host got Authorization %q (requested: %v)", auth, ok)
+		}
+	})
+
+	t.Run("not sent past a redirect to another origin", func(t *testing.T) {
+		target := filepath.Join(t.TempDir(), "private-1.0.0.tgz")
+		if err := downloadFile(registry.URL+"/npm/private/-/private-1.0.0.tgz", "", target); err != nil {
+			t.Fatal(err)
+		}
+		if auth, ok := registry.authorization("/npm/private/-/private-1.0.0.tgz"); auth != "Bearer s3cret" {
+			t.Errorf("registry got Authorization %q (requested: %v)", auth, ok)
+		}
+		if auth, ok := other.authorization("/cdn/private-1.0.0.tgz"); !ok || auth != "" {
+			t.Errorf("redirect target got Authorization %q (requested: %v)", auth, ok)
+		}
+	})
+}
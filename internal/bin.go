@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 )
 
 func LinkBinaries() error {
@@ -13,25 +14,21 @@ func LinkBinaries() error {
 		return err
 	}
 
-	binDir := filepath.Join(cwd, "node_modules", ".bin")
-	if err := os.MkdirAll(binDir, 0755); err != nil {
-		return err
-	}
+	return linkBinariesIn(filepath.Join(cwd, "node_modules"))
+}
 
-	nodeModulesDir := filepath.Join(cwd, "node_modules")
-	entries, err := os.ReadDir(nodeModulesDir)
-	if err != nil {
+// linkBinariesIn links the bins of every package in nodeModulesDir into its
+// own .bin directory, then descends into nested node_modules so that
+// packages installed under a dependent get their bins next to them.
+func linkBinariesIn(nodeModulesDir string) error {
+	binDir := filepath.Join(nodeModulesDir, ".bin")
+	if err := os.MkdirAll(binDir, 0755); err != nil {
 		return err
 	}
 
-	for _, entry := range entries {
-		if !entry.IsDir() || entry.Name() == ".bin" {
-			continue
-		}
-
-		pkgDir := filepath.Join(nodeModulesDir, entry.Name())
+	for _, pkgDir := range listPackageDirs(nodeModulesDir) {
 		pkgJsonPath := filepath.Join(pkgDir, "package.json")
-		
+
 		if _, err := os.Stat(pkgJsonPath); os.IsNotExist(err) {
 			continue
 		}
@@ -48,7 +45,7 @@ func LinkBinaries() error {
 				continue
 			}
 			os.Remove(absLinkPath)
-			
+
 			absBinPath, err := filepath.Abs(binPath)
 			if err != nil {
 				continue
@@ -56,18 +53,55 @@ func LinkBinaries() error {
 			if err := ensureExecutable(absBinPath); err != nil {
 				continue
 			}
-			
+
 			if err := createBinLink(absBinPath, absLinkPath); err != nil {
 				continue
 			}
-			
+
 			_ = ensureExecutable(absLinkPath)
 		}
+
+		if info, err := os.Lstat(pkgDir); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			continue // Linked packages manage their own node_modules
+		}
+		nested := filepath.Join(pkgDir, "node_modules")
+		if info, err := os.Lstat(nested); err == nil && info.IsDir() {
+			if err := linkBinariesIn(nested); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
 }
 
+// listPackageDirs returns the package directories inside a node_modules
+// directory, looking one level into @scope directories.
+func listPackageDirs(nodeModulesDir string) []string {
+	entries, err := os.ReadDir(nodeModulesDir)
+	if err != nil {
+		return nil
+	}
+
+	var dirs []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		path := filepath.Join(nodeModulesDir, name)
+		if info, err := os.Stat(path); err != nil || !info.IsDir() {
+			continue
+		}
+		if strings.HasPrefix(name, "@") {
+			dirs = append(dirs, listPackageDirs(path)...)
+			continue
+		}
+		dirs = append(dirs, path)
+	}
+	return dirs
+}
+
 func extractBinaries(pkgJsonPath, pkgDir string) (map[string]string, error) {
 	data, err := os.ReadFile(pkgJsonPath)
 	if err != nil {
@@ -75,7 +109,8 @@ func extractBinaries(pkgJsonPath, pkgDir string) (map[string]string, error) {
 	}
 
 	var pkgJson struct {
-		Bin interface{} `json:"bin"`
+		Name string      `json:"name"`
+		Bin  interface{} `json:"bin"`
 	}
 	if err := json.Unmarshal(data, &pkgJson); err != nil {
 		return nil, err
@@ -91,7 +126,12 @@ func extractBinaries(pkgJsonPath, pkgDir string) (map[string]string, error) {
 	case string:
 		binPath := filepath.Join(pkgDir, v)
 		if _, err := os.Stat(binPath); err == nil {
+			// Named after the package, not the directory, which differs for
+			// aliases.
 			pkgName := filepath.Base(pkgDir)
+			if pkgJson.Name != "" {
+				pkgName = pkgJson.Name[strings.LastIndex(pkgJson.Name, "/")+1:]
+			}
 			binaries[pkgName] = binPath
 		}
 	case map[string]interface{}:
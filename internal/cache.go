@@ -3,52 +3,88 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 )
 const (
 	cacheDir = ".tidy-cache"
-	cacheTTL = 24 * time.Hour  
+	// packumentAccept asks for the abbreviated install format, which leaves
+	// out readmes and everything else resolution does not need.
+	packumentAccept = "application/vnd.npm.install-v1+json; q=1.0, application/json; q=0.8, */*"
 )
-type CachedManifest struct {
-	Manifest Manifest  `json:"manifest"`
-	CachedAt time.Time `json:"cached_at"`
+// CachedPackument is a packument as the registry last sent it, with the
+// validators to revalidate it and how long the registry said it stays fresh.
+type CachedPackument struct {
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	CachedAt     time.Time `json:"cached_at"`
+	MaxAge       int       `json:"max_age,omitempty"`
+	Packument    Packument `json:"packument"`
+}
+func (c *CachedPackument) Fresh() bool {
+	return time.Since(c.CachedAt) < time.Duration(c.MaxAge)*time.Second
+}
+// revalidated records a response that confirmed or replaced the entry.
+func (c *CachedPackument) revalidated(header http.Header) {
+	if etag := header.Get("ETag"); etag != "" {
+		c.ETag = etag
+	}
+	if modified := header.Get("Last-Modified"); modified != "" {
+		c.LastModified = modified
+	}
+	c.CachedAt = time.Now()
+	c.MaxAge = cacheMaxAge(header)
+}
+// cacheMaxAge reads max-age from Cache-Control; without it an entry is
+// revalidated on every run.
+func cacheMaxAge(header http.Header) int {
+	maxAge := 0
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		directive = strings.TrimSpace(strings.ToLower(directive))
+		if directive == "no-cache" || directive == "no-store" {
+			return 0
+		}
+		if value, ok := strings.CutPrefix(directive, "max-age="); ok {
+			maxAge, _ = strconv.Atoi(value)
+		}
+	}
+	return maxAge
 }
 func getCacheDir() (string, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", err
 	}
-	cacheDir := filepath.Join(home, cacheDir, "manifests")
+	cacheDir := filepath.Join(home, cacheDir, "packuments")
 	return cacheDir, nil
 }
-func getCacheKey(pkg, version string) string {
-	hash := sha256.Sum256([]byte(pkg + "@" + version))
+// getCacheKey names the entry of a packument URL, so the same package name on
+// two registries does not share an entry.
+func getCacheKey(url string) string {
+	hash := sha256.Sum256([]byte(url))
 	return hex.EncodeToString(hash[:])
 }
-func LoadFromDiskCache(pkg, version string) (*Manifest, bool) {
+func LoadFromDiskCache(url string) (*CachedPackument, bool) {
 	cacheDir, err := getCacheDir()
 	if err != nil {
 		return nil, false
 	}
-	cacheKey := getCacheKey(pkg, version)
-	cachePath := filepath.Join(cacheDir, cacheKey+".json")
+	cachePath := filepath.Join(cacheDir, getCacheKey(url)+".json")
 	data, err := os.ReadFile(cachePath)
 	if err != nil {
 		return nil, false
 	}
-	var cached CachedManifest
+	var cached CachedPackument
 	if err := json.Unmarshal(data, &cached); err != nil {
 		return nil, false
 	}
-	if time.Since(cached.CachedAt) > cacheTTL {
-		os.Remove(cachePath)  
-		return nil, false
-	}
-	return &cached.Manifest, true
+	return &cached, true
 }
-func SaveToDiskCache(pkg, version string, manifest Manifest) error {
+func SaveToDiskCache(url string, cached *CachedPackument) error {
 	cacheDir, err := getCacheDir()
 	if err != nil {
 		return err
@@ -56,22 +92,28 @@ func SaveToDiskCache(pkg, version string, manifest Manifest) error {
 	if err := os.MkdirAll(cacheDir, 0755); err != nil {
 		return err
 	}
-	cached := CachedManifest{
-		Manifest: manifest,
-		CachedAt: time.Now(),
-	}
 	data, err := json.Marshal(cached)
 	if err != nil {
 		return err
 	}
-	cacheKey := getCacheKey(pkg, version)
-	cachePath := filepath.Join(cacheDir, cacheKey+".json")
-	return os.WriteFile(cachePath, data, 0644)
+	tmp, err := os.CreateTemp(cacheDir, "*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(cacheDir, getCacheKey(url)+".json"))
 }
 func ClearCache() error {
-	cacheDir, err := getCacheDir()
+	home, err := os.UserHomeDir()
 	if err != nil {
 		return err
 	}
-	return os.RemoveAll(cacheDir)
-}
\ No newline at end of file
+	return os.RemoveAll(filepath.Join(home, cacheDir))
+}
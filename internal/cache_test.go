@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCacheMaxAge(t *testing.T) {
+	tests := []struct {
+		cacheControl string
+		want         int
+	}{
+		{"", 0},
+		{"max-age=300", 300},
+		{"public, max-age=60", 60},
+		{"MAX-AGE=5", 5},
+		{"no-cache, max-age=60", 0},
+		{"max-age=60, no-store", 0},
+		{"max-age=soon", 0},
+	}
+	for _, tt := range tests {
+		header := http.Header{"Cache-Control": {tt.cacheControl}}
+		if got := cacheMaxAge(header); got != tt.want {
+			t.Errorf("cacheMaxAge(%q) = %d, want %d", tt.cacheControl, got, tt.want)
+		}
+	}
+}
+
+func TestPackumentRevalidation(t *testing.T) {
+	type request struct {
+		accept, ifNoneMatch, ifModifiedSince string
+	}
+	var mu sync.Mutex
+	var requests []request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, request{r.Header.Get("Accept"), r.Header.Get("If-None-Match"), r.Header.Get("If-Modified-Since")})
+		first := len(requests) == 1
+		mu.Unlock()
+		if first {
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+			w.Header().Set("Cache-Control", "max-age=0")
+			w.Write([]byte(`{"name": "ms", "dist-tags": {"latest": "2.1.3"}, "versions": {"2.1.3": {"name": "ms", "version": "2.1.3"}}}`))
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=300")
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer srv.Close()
+	useNpmrc(t, "registry="+srv.URL+"/\n")
+	url := srv.URL + "/ms"
+
+	if _, err := fetchPackument("ms", false); err != nil {
+		t.Fatal(err)
+	}
+	stale, ok := LoadFromDiskCache(url)
+	if !ok || stale.Fresh() {
+		t.Fatalf("cached = %+v, %v; want a stale entry", stale, ok)
+	}
+
+	// Stale, so it is revalidated and the 304 keeps the cached body.
+	packument, err := fetchPackument("ms", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := packument.Versions["2.1.3"]; !ok || packument.DistTags["latest"] != "2.1.3" {
+		t.Errorf("revalidated packument = %+v, want the cached one", packument)
+	}
+	refreshed, ok := LoadFromDiskCache(url)
+	if !ok {
+		t.Fatal("entry missing after revalidation")
+	}
+	if refreshed.MaxAge != 300 || !refreshed.Fresh() || !refreshed.CachedAt.After(stale.CachedAt) {
+		t.Errorf("refreshed entry = max-age %d, cached at %s; want max-age 300 after %s", refreshed.MaxAge, refreshed.CachedAt, stale.CachedAt)
+	}
+	if refreshed.ETag != `"v1"` || refreshed.LastModified != stale.LastModified {
+		t.Errorf("validators = %q, %q; want them kept", refreshed.ETag, refreshed.LastModified)
+	}
+
+	// Fresh now, so the registry is not asked again.
+	if _, err := fetchPackument("ms", false); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(requests) != 2 {
+		t.Fatalf("%d requests, want 2", len(requests))
+	}
+	if !strings.HasPrefix(requests[0].accept, "application/vnd.npm.install-v1+json") {
+		t.Errorf("Accept = %q, want the abbreviated format", requests[0].accept)
+	}
+	if got := requests[1]; got.ifNoneMatch != `"v1"` || got.ifModifiedSince != "Mon, 02 Jan 2006 15:04:05 GMT" {
+		t.Errorf("revalidation sent If-None-Match %q and If-Modified-Since %q", got.ifNoneMatch, got.ifModifiedSince)
+	}
+}
@@ -0,0 +1,217 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/chann44/tidy/internal/semver"
+)
+
+// GitCacheDirName holds bare clones of git dependencies, shared by every
+// project and refreshed at most once per run.
+const GitCacheDirName = ".tidy/git"
+
+var (
+	gitMu      sync.Mutex
+	gitFetched = make(map[string]bool)
+)
+
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// gitRepo returns the bare clone of url, cloning it on first use. An
+// existing clone is fetched unless it already has commit.
+func gitRepo(url, commit string) (string, error) {
+	lock := storeLock("git:" + url)
+	lock.Lock()
+	defer lock.Unlock()
+	repo, err := gitCachePath(url)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(repo); os.IsNotExist(err) {
+		if Offline() {
+			return "", fmt.Errorf("%s is not in the git cache", url)
+		}
+		if err := os.MkdirAll(filepath.Dir(repo), 0755); err != nil {
+			return "", err
+		}
+		tmp := repo + ".tmp"
+		os.RemoveAll(tmp)
+		if _, err := runGit("", "clone", "--bare", "--quiet", "--", url, tmp); err != nil {
+			os.RemoveAll(tmp)
+			return "", err
+		}
+		gitMu.Lock()
+		gitFetched[repo] = true
+		gitMu.Unlock()
+		return repo, os.Rename(tmp, repo)
+	}
+	if commit != "" {
+		if _, err := runGit(repo, "cat-file", "-e", "--end-of-options", commit+"^{commit}"); err == nil {
+			return repo, nil
+		}
+	}
+	gitMu.Lock()
+	fetched := gitFetched[repo]
+	gitMu.Unlock()
+	if !fetched && !Offline() {
+		if _, err := runGit(repo, "fetch", "--quiet", "--prune", "--tags", "--force", "--", url, "+refs/heads/*:refs/heads/*"); err != nil {
+			return "", err
+		}
+		gitMu.Lock()
+		gitFetched[repo] = true
+		gitMu.Unlock()
+	}
+	return repo, nil
+}
+
+func gitCachePath(url string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(home, GitCacheDirName, hex.EncodeToString(sum[:8])), nil
+}
+
+// commitSHA matches the full commit ids git dependencies are pinned to, SHA-1
+// or, in repositories using the sha256 object format, SHA-256.
+var commitSHA = regexp.MustCompile(`^(?:[0-9a-f]{40}|[0-9a-f]{64})$`)
+
+// gitSource returns the pinned git source of a package another package
+// manager fetched from a git repository, or from GitHub as a codeload tarball
+// of a commit.
+func gitSource(resolved string) (string, bool) {
+	if rest, ok := strings.CutPrefix(resolved, "https://codeload.github.com/"); ok {
+		parts := strings.Split(rest, "/")
+		if len(parts) != 4 || parts[2] != "tar.gz" {
+			return "", false
+		}
+		resolved = "github:" + parts[0] + "/" + parts[1] + "#" + parts[3]
+	}
+	g, ok := parseGitSpec(resolved)
+	if !ok || !commitSHA.MatchString(g.committish) {
+		return "", false
+	}
+	return g.resolved(g.committish), true
+}
+
+// gitCached reports whether commit is in the local clone of url.
+func gitCached(url, commit string) bool {
+	repo, err := gitCachePath(url)
+	if err != nil {
+		return false
+	}
+	_, err = runGit(repo, "cat-file", "-e", "--end-of-options", commit+"^{commit}")
+	return err == nil
+}
+
+// resolveGitCommit turns the ref of g into a full commit SHA. "#semver:"
+// picks the highest matching tag, with or without a leading v.
+func resolveGitCommit(repo string, g gitSpec) (string, error) {
+	ref := g.committish
+	if g.semver != "" {
+		rng, err := semver.ParseRange(g.semver)
+		if err != nil {
+			return "", err
+		}
+		out, err := runGit(repo, "tag", "--list")
+		if err != nil {
+			return "", err
+		}
+		tags := make(map[string]string)
+		var versions []string
+		for _, tag := range strings.Fields(out) {
+			if v, err := semver.Parse(tag); err == nil {
+				tags[v.String()] = tag
+				versions = append(versions, v.String())
+			}
+		}
+		best := semver.MaxSatisfying(versions, rng)
+		if best == "" {
+			return "", fmt.Errorf("no tag in %s satisfies %s", g.url, g.semver)
+		}
+		ref = tags[best]
+	}
+	if ref == "" {
+		ref = "HEAD"
+	}
+	commit, err := runGit(repo, "rev-parse", "--verify", "--quiet", "--end-of-options", ref+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("%s has no ref %s", g.url, ref)
+	}
+	return commit, nil
+}
+
+// FetchGitManifest resolves a git spec to a commit and reads package.json
+// from it. The returned tarball is the resolved git URL pinned to the commit.
+func FetchGitManifest(spec string) (Manifest, error) {
+	g, ok := parseGitSpec(spec)
+	if !ok {
+		return Manifest{}, fmt.Errorf("%q is not a git dependency", spec)
+	}
+	repo, err := gitRepo(g.url, "")
+	if err != nil {
+		return Manifest{}, err
+	}
+	commit, err := resolveGitCommit(repo, g)
+	if err != nil {
+		return Manifest{}, err
+	}
+	data, err := runGit(repo, "show", commit+":package.json")
+	if err != nil {
+		return Manifest{}, fmt.Errorf("%s#%s has no package.json", g.url, commit)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal([]byte(data), &manifest); err != nil {
+		return Manifest{}, fmt.Errorf("invalid package.json in %s#%s: %w", g.url, commit, err)
+	}
+	manifest.Dist.Tarball = g.resolved(commit)
+	return manifest, nil
+}
+
+// fetchGitToStore checks out the pinned commit and packs it into destDir the
+// way npm pack would. Lifecycle scripts such as prepare are not run.
+func fetchGitToStore(g gitSpec, destDir string) error {
+	repo, err := gitRepo(g.url, g.committish)
+	if err != nil {
+		return err
+	}
+	checkout, err := os.MkdirTemp("", "tidy-git-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(checkout)
+	if _, err := runGit("", "clone", "--quiet", "--no-checkout", "--", repo, checkout); err != nil {
+		return err
+	}
+	// The trailing -- makes git read the commit as a revision, never a path.
+	if _, err := runGit(checkout, "checkout", "--quiet", g.committish, "--"); err != nil {
+		return err
+	}
+	return packDir(checkout, destDir)
+}
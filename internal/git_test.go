@@ -0,0 +1,136 @@
+package internal
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gitFixture creates a repository holding a package at two tagged versions
+// and returns its file:// URL. initArgs are passed to git init.
+func gitFixture(t *testing.T, initArgs ...string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=tidy", "GIT_AUTHOR_EMAIL=tidy@example.com", "GIT_COMMITTER_NAME=tidy", "GIT_COMMITTER_EMAIL=tidy@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+	git(append([]string{"init", "--quiet"}, initArgs...)...)
+	for _, version := range []string{"1.0.0", "1.1.0"} {
+		manifest := `{"name": "fixture", "version": "` + version + `"}`
+		if err := os.WriteFile(filepath.Join(dir, "package.json"), []byte(manifest), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "index.js"), []byte("module.exports = '"+version+"'\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		git("add", ".")
+		git("commit", "--quiet", "-m", version)
+		git("tag", "v"+version)
+	}
+	return "file://" + filepath.ToSlash(dir)
+}
+
+func gitTestEnv(t *testing.T) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("NPM_CONFIG_GLOBALCONFIG", filepath.Join(home, "npmrc"))
+}
+
+func TestGitDependency(t *testing.T) {
+	gitTestEnv(t)
+	url := gitFixture(t)
+	sha256URL := gitFixture(t, "--object-format=sha256")
+	tests := []struct {
+		spec    string
+		version string
+	}{
+		{"git+" + url, "1.1.0"},
+		{"git+" + url + "#v1.0.0", "1.0.0"},
+		{"git+" + url + "#semver:~1.0.0", "1.0.0"},
+		{"git+" + url + "#semver:^1.0.0", "1.1.0"},
+		{"git+" + sha256URL + "#v1.0.0", "1.0.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			manifest, err := FetchGitManifest(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if manifest.Version != tt.version {
+				t.Fatalf("version = %s, want %s", manifest.Version, tt.version)
+			}
+			g, ok := parseGitSpec(manifest.Dist.Tarball)
+			if !ok || !commitSHA.MatchString(g.committish) {
+				t.Fatalf("%s is not pinned to a commit", manifest.Dist.Tarball)
+			}
+			dir, err := storePackage(Deps{Name: manifest.Name, Version: manifest.Version, Tarball: manifest.Dist.Tarball})
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(filepath.Join(dir, "index.js"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(data), tt.version) {
+				t.Errorf("store has %q, want version %s", data, tt.version)
+			}
+		})
+	}
+}
+
+func TestGitSpecRejectsOptions(t *testing.T) {
+	for _, spec := range []string{
+		"git+--upload-pack=touch /tmp/pwned",
+		"git+-c",
+		"git+https://example.com/repo.git#--upload-pack=x",
+	} {
+		if g, ok := parseGitSpec(spec); ok {
+			t.Errorf("parseGitSpec(%q) = %+v, want rejected", spec, g)
+		}
+	}
+}
+
+func TestGitStoreRequiresCommit(t *testing.T) {
+	gitTestEnv(t)
+	for _, committish := range []string{"main", "../../x", "v1.0.0"} {
+		deps := Deps{Name: "fixture", Version: "1.0.0", Tarball: "git+https://example.com/repo.git#" + committish}
+		if _, err := storePackage(deps); err == nil {
+			t.Errorf("storePackage accepted unpinned ref %q", committish)
+		}
+		if InStore(deps) {
+			t.Errorf("InStore accepted unpinned ref %q", committish)
+		}
+	}
+}
+
+func TestCommitSHA(t *testing.T) {
+	tests := []struct {
+		committish string
+		want       bool
+	}{
+		{strings.Repeat("a", 40), true},
+		{strings.Repeat("0", 64), true},
+		{strings.Repeat("a", 39), false},
+		{strings.Repeat("a", 41), false},
+		{strings.Repeat("a", 63), false},
+		{strings.Repeat("A", 40), false},
+		{"v1.0.0", false},
+	}
+	for _, tt := range tests {
+		if got := commitSHA.MatchString(tt.committish); got != tt.want {
+			t.Errorf("commitSHA(%q) = %v, want %v", tt.committish, got, tt.want)
+		}
+	}
+}
@@ -187,13 +187,16 @@ func writePackageJSON(path string, pkg PackageJson) error {
 	} else {
 		existing = make(map[string]interface{})
 	}
-	existing["dependencies"] = pkg.Dependencies
-	existing["devDependencies"] = pkg.DevDependencies
+	for field, deps := range map[string]map[string]string{"dependencies": pkg.Dependencies, "devDependencies": pkg.DevDependencies} {
+		if _, ok := existing[field]; ok || len(deps) > 0 {
+			existing[field] = deps
+		}
+	}
 	updatedData, err := json.MarshalIndent(existing, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, updatedData, 0644)
+	return os.WriteFile(path, append(updatedData, '\n'), 0644)
 }
 func extractPackageName(importPath string) string {
 	if idx := strings.Index(importPath, "?"); idx != -1 {
@@ -0,0 +1,150 @@
+package internal
+
+import (
+	"sort"
+	"strings"
+)
+
+// Layout maps a directory relative to the project root (for example
+// "node_modules/a/node_modules/debug") to the package key installed there.
+type Layout map[string]string
+
+// Hoist places every package of the graph as close to the root node_modules
+// as possible. A dependency is nested under its dependent only when a
+// different version of the same name is already visible from there, or when
+// the providers its peers were linked to would not be visible from the root,
+// in which case it stays next to them as in npm. Linked packages, workspaces
+// among them, get their dependencies placed relative to the directory they
+// link to, which is where Node resolves them from.
+func Hoist(res Resolved) Layout {
+	layout := make(Layout)
+	type placement struct {
+		path string
+		key  string
+	}
+	var queue []placement
+	for _, name := range sortedKeys(res.Dependencies) {
+		path := moduleDir("", name)
+		key := res.Dependencies[name]
+		layout[path] = key
+		queue = append(queue, placement{path: resolveFrom(path, res.Packages[key]), key: key})
+	}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		node, ok := res.Packages[current.key]
+		if !ok {
+			continue
+		}
+		// Packages with peers go last so that providers among their siblings
+		// are placed by the time they are.
+		names := sortedKeys(node.Dependencies)
+		sort.SliceStable(names, func(i, j int) bool {
+			return len(res.Packages[node.Dependencies[names[i]]].Peers) < len(res.Packages[node.Dependencies[names[j]]].Peers)
+		})
+		for _, name := range names {
+			key := node.Dependencies[name]
+			visible, found := layout.lookup(current.path, name)
+			if found && visible == key {
+				continue
+			}
+			path := moduleDir("", name)
+			if found || !layout.seesPeers(path, res.Packages[key]) {
+				path = moduleDir(current.path, name)
+			}
+			layout[path] = key
+			if !layout.hasAncestor(current.path, key) {
+				queue = append(queue, placement{path: resolveFrom(path, res.Packages[key]), key: key})
+			}
+		}
+	}
+	return layout
+}
+
+// lookup follows Node's module resolution from dir and reports the key of
+// the first package called name that it would find.
+func (l Layout) lookup(dir, name string) (string, bool) {
+	for {
+		if key, ok := l[moduleDir(dir, name)]; ok {
+			return key, true
+		}
+		if dir == "" {
+			return "", false
+		}
+		dir = parentDir(dir)
+	}
+}
+
+// seesPeers reports whether deps placed at dir would find the providers its
+// peers were linked to. Peers it also depends on are placed below it anyway.
+func (l Layout) seesPeers(dir string, deps Deps) bool {
+	for name, provider := range deps.Peers {
+		if _, ok := deps.Dependencies[name]; ok {
+			continue
+		}
+		if visible, ok := l.lookup(resolveFrom(dir, deps), name); !ok || visible != provider {
+			return false
+		}
+	}
+	return true
+}
+
+// hasAncestor guards against dependency cycles that would otherwise nest the
+// same package forever.
+func (l Layout) hasAncestor(dir, key string) bool {
+	for dir != "" {
+		if l[dir] == key {
+			return true
+		}
+		dir = parentDir(dir)
+	}
+	return false
+}
+
+// ByDepth groups the layout by nesting level so that a package is always
+// installed before anything nested inside its directory.
+func (l Layout) ByDepth() [][]string {
+	var levels [][]string
+	for _, path := range sortedKeys(l) {
+		depth := strings.Count(path, "node_modules/") - 1
+		if depth < 0 {
+			depth = 0
+		}
+		for len(levels) <= depth {
+			levels = append(levels, nil)
+		}
+		levels[depth] = append(levels[depth], path)
+	}
+	return levels
+}
+
+func resolveFrom(dir string, deps Deps) string {
+	if f, ok := parseFileSpec(deps.Tarball); ok && f.link {
+		return f.path
+	}
+	return dir
+}
+
+func moduleDir(dir, name string) string {
+	if dir == "" {
+		return "node_modules/" + name
+	}
+	return dir + "/node_modules/" + name
+}
+
+func parentDir(dir string) string {
+	idx := strings.LastIndex(dir, "/node_modules/")
+	if idx == -1 {
+		return ""
+	}
+	return dir[:idx]
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
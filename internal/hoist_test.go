@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func hoistPackage(name, version string, deps, peers map[string]string) Deps {
+	return Deps{
+		Name:         name,
+		Version:      version,
+		Tarball:      "https://registry.npmjs.org/" + name + "/-/" + name + "-" + version + ".tgz",
+		Dependencies: deps,
+		Peers:        peers,
+	}
+}
+
+func TestHoistPeers(t *testing.T) {
+	tests := []struct {
+		name string
+		res  Resolved
+		want Layout
+	}{
+		{
+			name: "shared provider is installed once",
+			res: Resolved{
+				Dependencies: map[string]string{"x": "x@1.0.0", "y": "y@1.0.0", "react": "react@17.0.0"},
+				Packages: map[string]Deps{
+					"x@1.0.0":      hoistPackage("x", "1.0.0", map[string]string{}, map[string]string{"react": "react@17.0.0"}),
+					"y@1.0.0":      hoistPackage("y", "1.0.0", map[string]string{}, map[string]string{"react": "react@17.0.0"}),
+					"react@17.0.0": hoistPackage("react", "17.0.0", map[string]string{}, nil),
+				},
+			},
+			want: Layout{
+				"node_modules/x":     "x@1.0.0",
+				"node_modules/y":     "y@1.0.0",
+				"node_modules/react": "react@17.0.0",
+			},
+		},
+		{
+			name: "dependent stays next to a nested provider",
+			res: Resolved{
+				Dependencies: map[string]string{"a": "a@1.0.0", "react": "react@18.0.0"},
+				Packages: map[string]Deps{
+					"a@1.0.0":      hoistPackage("a", "1.0.0", map[string]string{"b": "b@1.0.0", "react": "react@17.0.0"}, nil),
+					"b@1.0.0":      hoistPackage("b", "1.0.0", map[string]string{}, map[string]string{"react": "react@17.0.0"}),
+					"react@17.0.0": hoistPackage("react", "17.0.0", map[string]string{}, nil),
+					"react@18.0.0": hoistPackage("react", "18.0.0", map[string]string{}, nil),
+				},
+			},
+			want: Layout{
+				"node_modules/a":                    "a@1.0.0",
+				"node_modules/react":                "react@18.0.0",
+				"node_modules/a/node_modules/b":     "b@1.0.0",
+				"node_modules/a/node_modules/react": "react@17.0.0",
+			},
+		},
+		{
+			name: "dependent is hoisted when the root provides its peer",
+			res: Resolved{
+				Dependencies: map[string]string{"a": "a@1.0.0", "react": "react@18.0.0"},
+				Packages: map[string]Deps{
+					"a@1.0.0":      hoistPackage("a", "1.0.0", map[string]string{"b": "b@1.0.0"}, nil),
+					"b@1.0.0":      hoistPackage("b", "1.0.0", map[string]string{}, map[string]string{"react": "react@18.0.0"}),
+					"react@18.0.0": hoistPackage("react", "18.0.0", map[string]string{}, nil),
+				},
+			},
+			want: Layout{
+				"node_modules/a":     "a@1.0.0",
+				"node_modules/b":     "b@1.0.0",
+				"node_modules/react": "react@18.0.0",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Hoist(tt.res); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Hoist() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,55 +2,105 @@ package internal
 
 import (
 	"archive/tar"
+	"bufio"
 	"compress/gzip"
+	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
-func IsInstalled(name string) bool {
+// IsInstalled reports whether dir already holds deps. Packages from file:
+// directories are always reinstalled since their contents can change without
+// a version bump.
+func IsInstalled(dir string, deps Deps) bool {
 	cwd, err := os.Getwd()
 	if err != nil {
 		return false
 	}
-	packageDir := filepath.Join(cwd, "node_modules", name)
+	packageDir := filepath.Join(cwd, dir)
+	if f, ok := parseFileSpec(deps.Tarball); ok {
+		if !f.link {
+			return f.tarball && installedVersion(packageDir) == deps.Version
+		}
+		target, err := os.Readlink(packageDir)
+		return err == nil && target == linkTarget(packageDir, f.path)
+	}
+	return installedVersion(packageDir) == deps.Version
+}
+func installedVersion(packageDir string) string {
 	info, err := os.Stat(packageDir)
 	if err != nil || !info.IsDir() {
-		return false
+		return ""
+	}
+	data, err := os.ReadFile(filepath.Join(packageDir, "package.json"))
+	if err != nil {
+		return ""
+	}
+	var installed struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(data, &installed); err != nil {
+		return ""
 	}
-	packageJson := filepath.Join(packageDir, "package.json")
-	_, err = os.Stat(packageJson)
-	return err == nil
+	return installed.Version
 }
-
 const StoreDirName = ".tidy/store"
+var (
+	storeMu    sync.Mutex
+	storeLocks = make(map[string]*sync.Mutex)
+)
+// storeLock serializes work on one store entry, which can be wanted by
+// several layout directories installing at the same time.
+func storeLock(key string) *sync.Mutex {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	lock, ok := storeLocks[key]
+	if !ok {
+		lock = &sync.Mutex{}
+		storeLocks[key] = lock
+	}
+	return lock
+}
 
 func getStoreDir() (string, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", err
 	}
-	return filepath.Join(home, StoreDirName), nil
+	storeDir := filepath.Join(home, StoreDirName)
+	storeMigrateOnce.Do(func() {
+		storeMigrateErr = migrateStore(storeDir)
+	})
+	if storeMigrateErr != nil {
+		return "", fmt.Errorf("failed to migrate the store: %w", storeMigrateErr)
+	}
+	return storeDir, nil
 }
-func Install(name, url string) error {
-	storeDir, err := getStoreDir()
+// Install places deps at dir, a path relative to the project root taken from
+// the hoisted Layout.
+func Install(dir string, deps Deps) error {
+	cwd, err := os.Getwd()
 	if err != nil {
 		return err
 	}
-	pkgId := name + "@" + extractVersionFromUrl(url)
-	cachedPkgDir := filepath.Join(storeDir, pkgId)
-	if _, err := os.Stat(cachedPkgDir); os.IsNotExist(err) {
-		if err := downloadToStore(url, cachedPkgDir); err != nil {
+	targetDir := filepath.Join(cwd, filepath.FromSlash(dir))
+	if f, ok := parseFileSpec(deps.Tarball); ok && f.link {
+		os.RemoveAll(targetDir)
+		if err := os.MkdirAll(filepath.Dir(targetDir), 0755); err != nil {
 			return err
 		}
+		return os.Symlink(linkTarget(targetDir, f.path), targetDir)
 	}
-	cwd, err := os.Getwd()
+	cachedPkgDir, err := storePackage(deps)
 	if err != nil {
 		return err
 	}
-	targetDir := filepath.Join(cwd, "node_modules", name)
 	os.RemoveAll(targetDir)
 	if err := os.MkdirAll(targetDir, 0755); err != nil {
 		return err
@@ -60,69 +110,193 @@ func Install(name, url string) error {
 	}
 	return nil
 }
-func extractVersionFromUrl(url string) string {
-	parts := strings.Split(url, "-")
-	if len(parts) > 0 {
-		last := parts[len(parts)-1]
-		return strings.TrimSuffix(last, ".tgz")
+// linkTarget is the relative symlink from targetDir to a root-relative path.
+func linkTarget(targetDir, target string) string {
+	cwd, _ := os.Getwd()
+	abs := filepath.FromSlash(target)
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(cwd, abs)
+	}
+	rel, err := filepath.Rel(filepath.Dir(targetDir), abs)
+	if err != nil {
+		return abs
 	}
-	return "latest"
+	return rel
 }
-func downloadToStore(url, destDir string) error {
+// storePackage makes sure deps is unpacked in the store and returns its
+// directory there.
+func storePackage(deps Deps) (string, error) {
+	storeDir, err := getStoreDir()
+	if err != nil {
+		return "", err
+	}
+	if g, ok := parseGitSpec(deps.Tarball); ok {
+		if !commitSHA.MatchString(g.committish) {
+			return "", fmt.Errorf("git dependency %s is not pinned to a commit", deps.Tarball)
+		}
+		cachedPkgDir := filepath.Join(storeDir, "git", g.committish)
+		lock := storeLock("git:" + g.committish)
+		lock.Lock()
+		defer lock.Unlock()
+		if _, err := os.Stat(cachedPkgDir); os.IsNotExist(err) {
+			if err := fetchGitToStore(g, cachedPkgDir); err != nil {
+				return "", err
+			}
+		}
+		return cachedPkgDir, nil
+	}
+	if f, ok := parseFileSpec(deps.Tarball); ok {
+		return storeLocalPackage(storeDir, f)
+	}
+	if isTarballURL(deps.Tarball) && !isRegistryTarball(deps) {
+		return storeTarballURL(storeDir, deps.Tarball, deps.Integrity)
+	}
+	key := PackageKey(deps.Name, deps.Version)
+	lock := storeLock(key)
+	lock.Lock()
+	defer lock.Unlock()
+	if cachedPkgDir, ok := storeLookup(storeDir, key, deps.Integrity); ok {
+		return cachedPkgDir, nil
+	}
+	cachedPkgDir, err := fetchTarball(storeDir, key, registryTarball(deps.Name, deps.Tarball), deps.Integrity, mirrorName(deps))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", key, err)
+	}
+	return cachedPkgDir, nil
+}
+// InStore reports whether deps can be installed without the network: its
+// files are in the store already, or can be put there from a local source
+// or the mirror.
+func InStore(deps Deps) bool {
+	storeDir, err := getStoreDir()
+	if err != nil {
+		return false
+	}
+	if g, ok := parseGitSpec(deps.Tarball); ok {
+		if !commitSHA.MatchString(g.committish) {
+			return false
+		}
+		_, err := os.Stat(filepath.Join(storeDir, "git", g.committish))
+		return err == nil || gitCached(g.url, g.committish)
+	}
+	if f, ok := parseFileSpec(deps.Tarball); ok {
+		_, err := os.Stat(filepath.FromSlash(f.path))
+		return err == nil
+	}
+	if dir := mirrorDir(); dir != "" && isTarballURL(deps.Tarball) {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(mirrorName(deps)))); err == nil {
+			return true
+		}
+	}
+	key := PackageKey(deps.Name, deps.Version)
+	if isTarballURL(deps.Tarball) && !isRegistryTarball(deps) {
+		key = urlIndexKey(deps.Tarball)
+	}
+	_, ok := storeLookup(storeDir, key, deps.Integrity)
+	return ok
+}
+// downloadToStore streams the tarball at url into the store, indexed under
+// key, and returns its content directory.
+func downloadToStore(storeDir, key, url, integrity string) (string, error) {
+	var cachedPkgDir string
+	err := registryGet(url, nil, func(resp *http.Response) error {
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("failed to download %s: %s", url, resp.Status)
+		}
+		dir, err := storeTarball(storeDir, key, integrity, resp.Body)
+		if err != nil {
+			return fmt.Errorf("%s: %w", url, err)
+		}
+		cachedPkgDir = dir
+		return nil
+	})
+	return cachedPkgDir, err
+}
+// openTarball reads a package tarball, gzipped or not.
+func openTarball(r io.Reader) (*tar.Reader, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return tar.NewReader(gz), nil
+	}
+	return tar.NewReader(br), nil
+}
+// tarballEntryPath drops the top-level directory every entry of a package
+// tarball sits in ("package/" on the registry) and anything escaping it.
+func tarballEntryPath(name string) string {
+	_, rel, _ := strings.Cut(strings.TrimPrefix(path.Clean("/"+name), "/"), "/")
+	return rel
+}
+// extractTarball unpacks a package tarball into destDir, checking it against
+// the expected integrity on the way, and returns the integrity it has.
+// Nothing reaches destDir unless the check passes.
+func extractTarball(r io.Reader, integrity, destDir string) (string, error) {
+	hasher, err := newIntegrityHasher(integrity)
+	if err != nil {
+		return "", err
+	}
 	tempDir := destDir + ".tmp"
 	os.RemoveAll(tempDir)
 	if err := os.MkdirAll(tempDir, 0755); err != nil {
-		return err
+		return "", err
 	}
 	defer os.RemoveAll(tempDir)
-	resp, err := http.Get(url)
+	r = io.TeeReader(r, hasher)
+	tr, err := openTarball(r)
 	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	gz, err := gzip.NewReader(resp.Body)
-	if err != nil {
-		return err
+		return "", err
 	}
-	defer gz.Close()
-	tr := tar.NewReader(gz)
 	for {
 		header, err := tr.Next()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return err
+			return "", err
 		}
-		rel := strings.TrimPrefix(header.Name, "package/")
+		rel := tarballEntryPath(header.Name)
 		if rel == "" {
 			continue
 		}
-		target := filepath.Join(tempDir, rel)
+		target := filepath.Join(tempDir, filepath.FromSlash(rel))
 		switch header.Typeflag {
 		case tar.TypeDir:
 			if err := os.MkdirAll(target, 0755); err != nil {
-				return err
+				return "", err
 			}
 		case tar.TypeReg:
 			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
-				return err
+				return "", err
 			}
 			dst, err := os.Create(target)
 			if err != nil {
-				return err
+				return "", err
 			}
 			if _, err := io.Copy(dst, tr); err != nil {
 				dst.Close()
-				return err
+				return "", err
 			}
 			dst.Close()
 		}
 	}
+	// The tar stream ends before the gzip trailer, which is hashed too.
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return "", err
+	}
+	if err := hasher.Verify(); err != nil {
+		return "", err
+	}
 	if err := os.MkdirAll(filepath.Dir(destDir), 0755); err != nil {
-		return err
+		return "", err
+	}
+	os.RemoveAll(destDir)
+	if err := os.Rename(tempDir, destDir); err != nil {
+		return "", err
 	}
-	return os.Rename(tempDir, destDir)
+	return hasher.Sum(), nil
 }
 func linkPackage(src, dst string) error {
 	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
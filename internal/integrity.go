@@ -0,0 +1,126 @@
+package internal
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// integrityAlgorithms are the Subresource Integrity hashes tarballs can be
+// checked with, strongest first.
+var integrityAlgorithms = []struct {
+	name string
+	new  func() hash.Hash
+}{
+	{"sha512", sha512.New},
+	{"sha384", sha512.New384},
+	{"sha256", sha256.New},
+	{"sha1", sha1.New},
+}
+
+// integrity returns the SRI string of the tarball, made from the legacy hex
+// sha1 shasum for packages published before the registry had integrity.
+func (m Manifest) integrity() string {
+	if m.Dist.Integrity != "" {
+		return m.Dist.Integrity
+	}
+	if m.Dist.Shasum != "" {
+		return shasumToIntegrity(m.Dist.Shasum)
+	}
+	return ""
+}
+
+// parseIntegrity maps each algorithm of an SRI string to its base64 digest,
+// keeping the first digest given for an algorithm and dropping options.
+func parseIntegrity(sri string) map[string]string {
+	digests := make(map[string]string)
+	for _, field := range strings.Fields(sri) {
+		field, _, _ = strings.Cut(field, "?")
+		algorithm, digest, ok := strings.Cut(field, "-")
+		if _, seen := digests[algorithm]; ok && !seen {
+			digests[algorithm] = digest
+		}
+	}
+	return digests
+}
+
+// integrityMatches reports whether recorded, the integrity of a store entry,
+// agrees with expected on a hash both have. No expectation always matches.
+func integrityMatches(expected, recorded string) bool {
+	if expected == "" {
+		return true
+	}
+	have := parseIntegrity(recorded)
+	for algorithm, digest := range parseIntegrity(expected) {
+		if have[algorithm] == digest {
+			return true
+		}
+	}
+	return false
+}
+
+// integrityHasher hashes a tarball as it streams past, with sha512 and with
+// the strongest supported algorithm of the integrity it is expected to have.
+type integrityHasher struct {
+	algorithm string
+	expected  string
+	names     []string
+	hashes    []hash.Hash
+}
+
+func newIntegrityHasher(expected string) (*integrityHasher, error) {
+	h := &integrityHasher{}
+	digests := parseIntegrity(expected)
+	for _, algorithm := range integrityAlgorithms {
+		if digest, ok := digests[algorithm.name]; ok && h.algorithm == "" {
+			h.algorithm, h.expected = algorithm.name, digest
+		}
+		if algorithm.name == "sha512" || algorithm.name == h.algorithm {
+			h.names = append(h.names, algorithm.name)
+			h.hashes = append(h.hashes, algorithm.new())
+		}
+	}
+	if expected != "" && h.algorithm == "" {
+		return nil, fmt.Errorf("unsupported integrity %q", expected)
+	}
+	return h, nil
+}
+
+func (h *integrityHasher) Write(p []byte) (int, error) {
+	for _, hash := range h.hashes {
+		hash.Write(p)
+	}
+	return len(p), nil
+}
+
+func (h *integrityHasher) digest(algorithm string) string {
+	for i, name := range h.names {
+		if name == algorithm {
+			return base64.StdEncoding.EncodeToString(h.hashes[i].Sum(nil))
+		}
+	}
+	return ""
+}
+
+// Sum is the SRI string of everything written so far.
+func (h *integrityHasher) Sum() string {
+	sums := make([]string, len(h.names))
+	for i, name := range h.names {
+		sums[i] = name + "-" + h.digest(name)
+	}
+	return strings.Join(sums, " ")
+}
+
+func (h *integrityHasher) Verify() error {
+	if h.algorithm == "" {
+		return nil
+	}
+	if actual := h.digest(h.algorithm); actual != h.expected {
+		return fmt.Errorf("integrity check failed: expected %s-%s, got %s-%s", h.algorithm, h.expected, h.algorithm, actual)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha512"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// makeTarball packs files the way the registry does, under "package/".
+func makeTarball(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range sortedKeys(files) {
+		body := files[name]
+		if err := tw.WriteHeader(&tar.Header{Name: "package/" + name, Mode: 0644, Size: int64(len(body)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func sha512Integrity(data []byte) string {
+	sum := sha512.Sum512(data)
+	return "sha512-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestStorePackageIntegrityMismatch(t *testing.T) {
+	tarball := makeTarball(t, map[string]string{"package.json": `{"name": "ms", "version": "2.1.3"}`})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(tarball)
+	}))
+	defer srv.Close()
+	useNpmrc(t, "registry="+srv.URL+"/\n")
+	storeDir, err := getStoreDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	deps := Deps{Name: "ms", Version: "2.1.3", Tarball: srv.URL + "/ms/-/ms-2.1.3.tgz"}
+
+	tests := []struct {
+		name      string
+		integrity string
+		wantErr   bool
+	}{
+		{"mismatch", sha512Integrity([]byte("something else")), true},
+		{"match", sha512Integrity(tarball), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps.Integrity = tt.integrity
+			dir, err := storePackage(deps)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want, _ := contentDir(storeDir, tt.integrity); dir != want {
+					t.Errorf("stored at %q, want %q", dir, want)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), "ms@2.1.3") || !strings.Contains(err.Error(), "integrity check failed") {
+				t.Fatalf("storePackage = %v; want an integrity error naming ms@2.1.3", err)
+			}
+			if _, err := os.Stat(filepath.Join(storeDir, storeContentDir)); !os.IsNotExist(err) {
+				t.Errorf("a store entry was created")
+			}
+			if got := storeIndex(storeDir, "ms@2.1.3"); got != "" {
+				t.Errorf("index for ms@2.1.3 = %q, want none", got)
+			}
+			leftover, _ := os.ReadDir(filepath.Join(storeDir, storeTempDir))
+			if len(leftover) > 0 {
+				t.Errorf("temporary files left in the store: %v", leftover)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// localPacked records the file: directories packed into the store during
+// this run, so each is repacked once per install rather than once per copy.
+var localPacked = make(map[string]bool)
+
+// storeLocalPackage puts a file: dependency into the store. Tarballs go to
+// the content store, directories are keyed by their path and repacked each
+// run.
+func storeLocalPackage(storeDir string, f fileSpec) (string, error) {
+	abs, err := filepath.Abs(filepath.FromSlash(f.path))
+	if err != nil {
+		return "", err
+	}
+	lock := storeLock("file:" + abs)
+	lock.Lock()
+	defer lock.Unlock()
+	if f.tarball {
+		file, err := os.Open(abs)
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+		hasher, _ := newIntegrityHasher("")
+		if _, err := io.Copy(hasher, file); err != nil {
+			return "", err
+		}
+		integrity := hasher.Sum()
+		if cachedPkgDir, ok := storeLookup(storeDir, "", integrity); ok {
+			return cachedPkgDir, nil
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+		return storeTarball(storeDir, "", integrity, file)
+	}
+	sum := sha256.Sum256([]byte(abs))
+	cachedPkgDir := filepath.Join(storeDir, "local", hex.EncodeToString(sum[:8]))
+	storeMu.Lock()
+	packed := localPacked[cachedPkgDir]
+	storeMu.Unlock()
+	if packed {
+		return cachedPkgDir, nil
+	}
+	if err := packDir(abs, cachedPkgDir); err != nil {
+		return "", err
+	}
+	storeMu.Lock()
+	localPacked[cachedPkgDir] = true
+	storeMu.Unlock()
+	return cachedPkgDir, nil
+}
+
+// storeTarballURL downloads a tarball dependency into the store, indexed by
+// its URL. integrity is empty while resolving, when it is not known yet.
+func storeTarballURL(storeDir, url, integrity string) (string, error) {
+	key := urlIndexKey(url)
+	lock := storeLock(key)
+	lock.Lock()
+	defer lock.Unlock()
+	if cachedPkgDir, ok := storeLookup(storeDir, key, integrity); ok {
+		return cachedPkgDir, nil
+	}
+	return fetchTarball(storeDir, key, url, integrity, urlMirrorName(url))
+}
+
+// readFileManifest reads package.json of a file: or link: dependency,
+// looking inside the archive for tarballs.
+func readFileManifest(f fileSpec) (Manifest, error) {
+	var manifest Manifest
+	var err error
+	if f.tarball {
+		manifest, err = readTarballManifest(filepath.FromSlash(f.path))
+	} else {
+		manifest, err = readManifestFile(filepath.Join(filepath.FromSlash(f.path), "package.json"))
+	}
+	if err != nil {
+		return Manifest{}, err
+	}
+	manifest.Dist.Tarball = f.String()
+	return manifest, nil
+}
+
+// FetchTarballManifest downloads a tarball URL dependency into the store and
+// reads its package.json, with the integrity of the download so the lock can
+// pin it.
+func FetchTarballManifest(url string) (Manifest, error) {
+	storeDir, err := getStoreDir()
+	if err != nil {
+		return Manifest{}, err
+	}
+	cachedPkgDir, err := storeTarballURL(storeDir, url, "")
+	if err != nil {
+		return Manifest{}, err
+	}
+	manifest, err := readManifestFile(filepath.Join(cachedPkgDir, "package.json"))
+	if err != nil {
+		return Manifest{}, err
+	}
+	manifest.Dist.Tarball = url
+	manifest.Dist.Integrity = storeIntegrity(storeDir, urlIndexKey(url))
+	return manifest, nil
+}
+
+func readManifestFile(file string) (Manifest, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return Manifest{}, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return Manifest{}, fmt.Errorf("invalid %s: %w", file, err)
+	}
+	return manifest, nil
+}
+
+func readTarballManifest(file string) (Manifest, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return Manifest{}, err
+	}
+	defer f.Close()
+	tr, err := openTarball(f)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("invalid tarball %s: %w", file, err)
+	}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return Manifest{}, fmt.Errorf("%s has no package.json", file)
+		}
+		if err != nil {
+			return Manifest{}, fmt.Errorf("invalid tarball %s: %w", file, err)
+		}
+		if tarballEntryPath(header.Name) != "package.json" {
+			continue
+		}
+		var manifest Manifest
+		if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+			return Manifest{}, fmt.Errorf("invalid package.json in %s: %w", file, err)
+		}
+		return manifest, nil
+	}
+}
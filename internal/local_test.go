@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseFileSpec(t *testing.T) {
+	tests := []struct {
+		spec   string
+		want   fileSpec
+		parsed bool
+	}{
+		{"file:../lib", fileSpec{path: "../lib"}, true},
+		{"file:./lib/", fileSpec{path: "lib"}, true},
+		{"./lib", fileSpec{path: "lib"}, true},
+		{"/abs/lib", fileSpec{path: "/abs/lib"}, true},
+		{"file:vendor/pkg-1.0.0.tgz", fileSpec{path: "vendor/pkg-1.0.0.tgz", tarball: true}, true},
+		{"file:vendor/pkg.tar", fileSpec{path: "vendor/pkg.tar", tarball: true}, true},
+		{"link:packages/a", fileSpec{path: "packages/a", link: true}, true},
+		{"link:vendor/pkg.tgz", fileSpec{path: "vendor/pkg.tgz", link: true}, true},
+		{"^1.0.0", fileSpec{}, false},
+		{"github:org/lib", fileSpec{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseFileSpec(tt.spec)
+		if got != tt.want || ok != tt.parsed {
+			t.Errorf("parseFileSpec(%q) = %+v, %v; want %+v, %v", tt.spec, got, ok, tt.want, tt.parsed)
+		}
+	}
+}
+
+func TestLocalDependencies(t *testing.T) {
+	useNpmrc(t, "")
+	project := t.TempDir()
+	t.Chdir(project)
+	if err := os.MkdirAll("lib", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join("lib", "package.json"), []byte(`{"name": "lib", "version": "1.0.0"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tarball := makeTarball(t, map[string]string{"package.json": `{"name": "packed", "version": "2.0.0"}`})
+	if err := os.WriteFile("packed-2.0.0.tgz", tarball, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		spec    string
+		name    string
+		version string
+		// symlink is set for link: dependencies, which are not copied.
+		symlink bool
+	}{
+		{"file:lib", "lib", "1.0.0", false},
+		{"file:packed-2.0.0.tgz", "packed", "2.0.0", false},
+		{"link:lib", "lib", "1.0.0", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			manifest, source, err := fetchEdgeManifest("dep", tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if manifest.Name != tt.name || manifest.Version != tt.version || source != tt.spec {
+				t.Fatalf("manifest = %s@%s from %q; want %s@%s from %q", manifest.Name, manifest.Version, source, tt.name, tt.version, tt.spec)
+			}
+			deps := Deps{Name: manifest.Name, Version: manifest.Version, Tarball: source}
+			dir := "node_modules/dep"
+			if err := Install(dir, deps); err != nil {
+				t.Fatal(err)
+			}
+			info, err := os.Lstat(filepath.FromSlash(dir))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := info.Mode()&os.ModeSymlink != 0; got != tt.symlink {
+				t.Errorf("symlink = %v, want %v", got, tt.symlink)
+			}
+			if tt.symlink {
+				if target, _ := os.Readlink(filepath.FromSlash(dir)); target != filepath.Join("..", "lib") {
+					t.Errorf("link target = %q, want ../lib", target)
+				}
+			}
+			if got := installedVersion(filepath.FromSlash(dir)); got != tt.version {
+				t.Errorf("installed version = %q, want %q", got, tt.version)
+			}
+			// Directories can change without a version bump, so only links and
+			// tarballs count as installed.
+			if got, want := IsInstalled(dir, deps), tt.spec != "file:lib"; got != want {
+				t.Errorf("IsInstalled = %v, want %v", got, want)
+			}
+		})
+	}
+}
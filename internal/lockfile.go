@@ -0,0 +1,255 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	LockfileName    = "tidy.lock"
+	lockfileVersion = 1
+)
+
+// Lockfile is the committed record of a resolution. Specifiers holds the
+// ranges from package.json the graph was resolved for, so a stale lock can be
+// detected without touching the registry. Workspaces holds the same for each
+// workspace, keyed by its directory.
+type Lockfile struct {
+	LockfileVersion int                          `json:"lockfileVersion"`
+	Specifiers      map[string]string            `json:"specifiers"`
+	Overrides       map[string]string            `json:"overrides,omitempty"`
+	Workspaces      map[string]map[string]string `json:"workspaces,omitempty"`
+	Dependencies    map[string]string            `json:"dependencies"`
+	Packages        map[string]Deps              `json:"packages"`
+}
+
+func NewLockfile(pkgs PackageJson, workspaces []Workspace, resolved Resolved) *Lockfile {
+	return &Lockfile{
+		LockfileVersion: lockfileVersion,
+		Specifiers:      rootSpecifiers(pkgs),
+		Overrides:       overrideSpecs(parseOverrides(pkgs)),
+		Workspaces:      workspaceSpecifiers(workspaces),
+		Dependencies:    resolved.Dependencies,
+		Packages:        resolved.Packages,
+	}
+}
+
+func ReadLockfile(root string) (*Lockfile, error) {
+	data, err := os.ReadFile(filepath.Join(root, LockfileName))
+	if err != nil {
+		return nil, err
+	}
+	var lock Lockfile
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", LockfileName, err)
+	}
+	if lock.LockfileVersion != lockfileVersion {
+		return nil, fmt.Errorf("unsupported %s version %d", LockfileName, lock.LockfileVersion)
+	}
+	if lock.Dependencies == nil {
+		lock.Dependencies = make(map[string]string)
+	}
+	if lock.Packages == nil {
+		lock.Packages = make(map[string]Deps)
+	}
+	return &lock, nil
+}
+
+func WriteLockfile(root string, lock *Lockfile) error {
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(root, LockfileName), append(data, '\n'), 0644)
+}
+
+func (l *Lockfile) Resolved() Resolved {
+	resolved := newResolved()
+	for name, key := range l.Dependencies {
+		resolved.Dependencies[name] = key
+	}
+	for key, deps := range l.Packages {
+		if deps.Dependencies == nil {
+			deps.Dependencies = make(map[string]string)
+		}
+		resolved.Packages[key] = deps
+	}
+	return resolved
+}
+
+// Diff lists every way package.json, the workspaces and the lock disagree;
+// an empty result means the lock can be installed as is.
+func (l *Lockfile) Diff(pkgs PackageJson, workspaces []Workspace) []string {
+	problems := diffSpecifiers("package.json", rootSpecifiers(pkgs), l.Specifiers)
+	wanted := workspaceSpecifiers(workspaces)
+	for _, dir := range sortedKeys(wanted) {
+		problems = append(problems, diffSpecifiers(dir+"/package.json", wanted[dir], l.Workspaces[dir])...)
+	}
+	for _, dir := range sortedKeys(l.Workspaces) {
+		if _, ok := wanted[dir]; !ok {
+			problems = append(problems, fmt.Sprintf("workspace %s is in %s but no longer exists", dir, LockfileName))
+		}
+	}
+	overrides := overrideSpecs(parseOverrides(pkgs))
+	for _, rule := range sortedKeys(overrides) {
+		if locked, ok := l.Overrides[rule]; !ok || locked != overrides[rule] {
+			problems = append(problems, fmt.Sprintf("override %s: package.json forces %q, %s has %q", rule, overrides[rule], LockfileName, locked))
+		}
+	}
+	for _, rule := range sortedKeys(l.Overrides) {
+		if _, ok := overrides[rule]; !ok {
+			problems = append(problems, fmt.Sprintf("override %s is in %s but not in package.json", rule, LockfileName))
+		}
+	}
+	for _, name := range sortedKeys(l.Dependencies) {
+		problems = append(problems, l.missingPackages(name, l.Dependencies[name])...)
+	}
+	for _, key := range sortedKeys(l.Packages) {
+		deps := l.Packages[key]
+		for _, name := range sortedKeys(deps.Dependencies) {
+			problems = append(problems, l.missingPackages(key+" > "+name, deps.Dependencies[name])...)
+		}
+		for _, name := range sortedKeys(deps.Peers) {
+			problems = append(problems, l.missingPackages(key+" > peer "+name, deps.Peers[name])...)
+		}
+	}
+	return problems
+}
+
+func diffSpecifiers(file string, wanted, locked map[string]string) []string {
+	var problems []string
+	for _, name := range sortedKeys(wanted) {
+		spec, ok := locked[name]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%s@%s is in %s but not in %s", name, wanted[name], file, LockfileName))
+		case spec != wanted[name]:
+			problems = append(problems, fmt.Sprintf("%s: %s wants %q, %s has %q", name, file, wanted[name], LockfileName, spec))
+		}
+	}
+	for _, name := range sortedKeys(locked) {
+		if _, ok := wanted[name]; !ok {
+			problems = append(problems, fmt.Sprintf("%s is in %s but not in %s", name, LockfileName, file))
+		}
+	}
+	return problems
+}
+
+// declaredSpecifiers returns the specifiers the lock was resolved for, keyed
+// by the directory of the package.json declaring them ("" for the root).
+func (l *Lockfile) declaredSpecifiers() map[string]map[string]string {
+	specs := map[string]map[string]string{"": l.Specifiers}
+	for dir, s := range l.Workspaces {
+		specs[dir] = s
+	}
+	return specs
+}
+func (l *Lockfile) missingPackages(from, key string) []string {
+	if _, ok := l.Packages[key]; ok {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s points at %s, which is missing from %s", from, key, LockfileName)}
+}
+
+// ResolveFrozen returns exactly what tidy.lock records and refuses to run
+// when the lock is missing or out of date with package.json.
+func ResolveFrozen(root string, pkgs PackageJson) (Resolved, error) {
+	lock, err := ReadLockfile(root)
+	if os.IsNotExist(err) {
+		return Resolved{}, fmt.Errorf("%s not found; run tidy install to create it", LockfileName)
+	}
+	if err != nil {
+		return Resolved{}, err
+	}
+	workspaces, err := FindWorkspaces(root, pkgs)
+	if err != nil {
+		return Resolved{}, err
+	}
+	if problems := lock.Diff(pkgs, workspaces); len(problems) > 0 {
+		return Resolved{}, fmt.Errorf("%s is out of date with package.json:\n  - %s", LockfileName, strings.Join(problems, "\n  - "))
+	}
+	return lock.Resolved(), nil
+}
+
+// lockfileImporters are tried in order when a project has no tidy.lock yet.
+var lockfileImporters = []struct {
+	file  string
+	parse func([]byte, PackageJson, []Workspace) (Resolved, error)
+}{
+	{NpmLockfileName, parseNpmLockfile},
+	{YarnLockfileName, parseYarnLockfile},
+	{PnpmLockfileName, parsePnpmLockfile},
+}
+
+// ImportLockfile reads the first lockfile of another package manager found in
+// root that can be imported and returns its graph along with the file it came
+// from. Workspaces key the nodes of lockfiles that record them by directory
+// alone. Lockfiles passed over because they failed to import are reported by
+// ImportWarnings; when none can be imported the error lists why.
+func ImportLockfile(root string, pkgs PackageJson, workspaces []Workspace) (Resolved, string, error) {
+	var failed []error
+	source := ""
+	for _, importer := range lockfileImporters {
+		data, err := os.ReadFile(filepath.Join(root, importer.file))
+		if os.IsNotExist(err) {
+			continue
+		}
+		var resolved Resolved
+		if err == nil {
+			resolved, err = importer.parse(data, pkgs, workspaces)
+		}
+		if err != nil {
+			failed = append(failed, err)
+			if source == "" {
+				source = importer.file
+			}
+			continue
+		}
+		for _, err := range failed {
+			warnImport(fmt.Sprintf("imported %s instead: %v", importer.file, err))
+		}
+		return resolved, importer.file, nil
+	}
+	if len(failed) > 0 {
+		return Resolved{}, source, errors.Join(failed...)
+	}
+	return Resolved{}, "", fmt.Errorf("no lockfile to import found in %s", root)
+}
+
+var (
+	importMu  sync.Mutex
+	importLog []string
+)
+
+func warnImport(msg string) {
+	importMu.Lock()
+	defer importMu.Unlock()
+	importLog = append(importLog, msg)
+}
+
+// ImportWarnings describes the lockfiles of other package managers that
+// could not be imported, leaving their versions unpinned.
+func ImportWarnings() []string {
+	importMu.Lock()
+	defer importMu.Unlock()
+	return append([]string(nil), importLog...)
+}
+
+func rootSpecifiers(pkgs PackageJson) map[string]string {
+	specifiers := make(map[string]string)
+	for name, spec := range pkgs.Dependencies {
+		specifiers[name] = spec
+	}
+	for name, spec := range pkgs.DevDependencies {
+		specifiers[name] = spec
+	}
+	for name, spec := range pkgs.OptionalDependencies {
+		specifiers[name] = spec
+	}
+	return specifiers
+}
@@ -0,0 +1,42 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestImportLockfileFallback(t *testing.T) {
+	useNpmrc(t, "")
+	root := t.TempDir()
+	write := func(name, contents string) {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pkgs := PackageJson{Dependencies: map[string]string{"ms": "^2.1.0"}}
+	write(NpmLockfileName, `{"lockfileVersion": 1, "dependencies": {}}`)
+
+	_, source, err := ImportLockfile(root, pkgs, nil)
+	if err == nil || source != NpmLockfileName || !strings.Contains(err.Error(), "v1 is not supported") {
+		t.Fatalf("ImportLockfile = %q, %v; want the v1 error from %s", source, err, NpmLockfileName)
+	}
+
+	write(YarnLockfileName, `ms@^2.1.0:
+  version "2.1.3"
+  resolved "https://registry.yarnpkg.com/ms/-/ms-2.1.3.tgz"
+`)
+	before := len(ImportWarnings())
+	resolved, source, err := ImportLockfile(root, pkgs, nil)
+	if err != nil || source != YarnLockfileName {
+		t.Fatalf("ImportLockfile = %q, %v; want %s", source, err, YarnLockfileName)
+	}
+	if got := resolved.Dependencies["ms"]; got != "ms@2.1.3" {
+		t.Errorf("root edge to ms = %q, want ms@2.1.3", got)
+	}
+	warnings := ImportWarnings()[before:]
+	if len(warnings) != 1 || !strings.Contains(warnings[0], NpmLockfileName) || !strings.Contains(warnings[0], YarnLockfileName) {
+		t.Errorf("warnings = %q, want one naming both lockfiles", warnings)
+	}
+}
@@ -0,0 +1,209 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// MirrorDirName is where tidy fetch puts the offline mirror by default,
+// relative to the project root.
+const MirrorDirName = ".tidy/mirror"
+
+// mirrorDir is the mirror installs read from, set with the mirror setting.
+func mirrorDir() string {
+	return config()["mirror"]
+}
+
+// mirrorName is the path of a package's tarball inside a mirror:
+// <name>-<version>.tgz, scoped packages sitting in a directory for their
+// scope. Tarball URL dependencies are named after the URL instead.
+func mirrorName(deps Deps) string {
+	if isTarballURL(deps.Tarball) && !isRegistryTarball(deps) {
+		return urlMirrorName(deps.Tarball)
+	}
+	return deps.Name + "-" + deps.Version + ".tgz"
+}
+
+func urlMirrorName(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return "url/" + hex.EncodeToString(sum[:8]) + ".tgz"
+}
+
+func mirrorPackumentPath(dir, name string) string {
+	return filepath.Join(dir, "packuments", filepath.FromSlash(name)+".json")
+}
+
+// fetchTarball puts the tarball at url into the store under key, taking it
+// from the mirror instead of the network when installing from one.
+func fetchTarball(storeDir, key, url, integrity, mirrored string) (string, error) {
+	dir := mirrorDir()
+	if dir == "" {
+		return downloadToStore(storeDir, key, url, integrity)
+	}
+	f, err := os.Open(filepath.Join(dir, filepath.FromSlash(mirrored)))
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("%s is not in the mirror %s", mirrored, dir)
+	}
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	cachedPkgDir, err := storeTarball(storeDir, key, integrity, f)
+	if err != nil {
+		return "", fmt.Errorf("%s in the mirror: %w", mirrored, err)
+	}
+	return cachedPkgDir, nil
+}
+
+func readMirrorPackument(dir, name string) (*Packument, error) {
+	data, err := os.ReadFile(mirrorPackumentPath(dir, name))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s is not in the mirror %s", name, dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var packument Packument
+	if err := json.Unmarshal(data, &packument); err != nil {
+		return nil, fmt.Errorf("invalid mirrored packument for %s: %w", name, err)
+	}
+	return &packument, nil
+}
+
+// FetchMirror downloads the tarball of every registry and tarball URL
+// package of the graph into dir, along with the packuments of the registry
+// ones trimmed to the mirrored versions, so that a later install can resolve
+// and install from dir alone. Local packages are left alone, and git ones
+// cannot be mirrored and are returned as skipped.
+func FetchMirror(res Resolved, dir string) (fetched, skipped []string, err error) {
+	packuments := make(map[string][]string)
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs []string
+	)
+	semaphore := make(chan struct{}, 16)
+	for _, key := range sortedKeys(res.Packages) {
+		deps := res.Packages[key]
+		if _, ok := parseGitSpec(deps.Tarball); ok {
+			skipped = append(skipped, key)
+			continue
+		}
+		if !isTarballURL(deps.Tarball) {
+			continue
+		}
+		if isRegistryTarball(deps) {
+			packuments[deps.Name] = append(packuments[deps.Name], deps.Version)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(mirrorName(deps)))
+		if _, err := os.Stat(target); err == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(key string, deps Deps) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			url := deps.Tarball
+			if isRegistryTarball(deps) {
+				url = registryTarball(deps.Name, url)
+			}
+			err := downloadFile(url, deps.Integrity, target)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", key, err))
+				return
+			}
+			fetched = append(fetched, key)
+		}(key, deps)
+	}
+	wg.Wait()
+	for _, name := range sortedKeys(packuments) {
+		if err := mirrorPackument(dir, name, packuments[name]); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fetched, skipped, fmt.Errorf("failed to mirror %d package(s):\n  - %s", len(errs), strings.Join(errs, "\n  - "))
+	}
+	return fetched, skipped, nil
+}
+
+// mirrorPackument writes the packument of name with only versions left in
+// it, and the dist-tags pointing at them, merging with what the mirror has.
+func mirrorPackument(dir, name string, versions []string) error {
+	full, err := FetchPackument(name)
+	if err != nil {
+		return err
+	}
+	trimmed := Packument{Name: full.Name, DistTags: make(map[string]string), Versions: make(map[string]Manifest)}
+	if existing, err := readMirrorPackument(dir, name); err == nil {
+		trimmed = *existing
+	}
+	for _, version := range versions {
+		if manifest, ok := full.Versions[version]; ok {
+			trimmed.Versions[version] = manifest
+		}
+	}
+	for tag, version := range full.DistTags {
+		if _, ok := trimmed.Versions[version]; ok {
+			trimmed.DistTags[tag] = version
+		}
+	}
+	data, err := json.MarshalIndent(trimmed, "", "  ")
+	if err != nil {
+		return err
+	}
+	target := mirrorPackumentPath(dir, name)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(target, append(data, '\n'), 0644)
+}
+
+// downloadFile saves the body at url as target, only once it is complete and
+// matches integrity.
+func downloadFile(url, integrity, target string) error {
+	if _, err := newIntegrityHasher(integrity); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	tmp := target + ".tmp"
+	defer os.Remove(tmp)
+	err := registryGet(url, nil, func(resp *http.Response) error {
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("failed to download %s: %s", url, resp.Status)
+		}
+		f, err := os.Create(tmp)
+		if err != nil {
+			return err
+		}
+		hasher, _ := newIntegrityHasher(integrity)
+		if _, err := io.Copy(io.MultiWriter(f, hasher), resp.Body); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		if err := hasher.Verify(); err != nil {
+			return fmt.Errorf("%s: %w", url, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, target)
+}
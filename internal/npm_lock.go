@@ -0,0 +1,259 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const NpmLockfileName = "package-lock.json"
+
+type npmLockfile struct {
+	Name            string                    `json:"name"`
+	Version         string                    `json:"version,omitempty"`
+	LockfileVersion int                       `json:"lockfileVersion"`
+	Requires        bool                      `json:"requires,omitempty"`
+	Packages        map[string]npmLockPackage `json:"packages"`
+}
+
+type npmLockPackage struct {
+	Name                 string            `json:"name,omitempty"`
+	Version              string            `json:"version,omitempty"`
+	Workspaces           []string          `json:"workspaces,omitempty"`
+	Resolved             string            `json:"resolved,omitempty"`
+	Integrity            string            `json:"integrity,omitempty"`
+	Link                 bool              `json:"link,omitempty"`
+	Dev                  bool              `json:"dev,omitempty"`
+	Optional             bool              `json:"optional,omitempty"`
+	Dependencies         map[string]string `json:"dependencies,omitempty"`
+	DevDependencies      map[string]string `json:"devDependencies,omitempty"`
+	OptionalDependencies map[string]string `json:"optionalDependencies,omitempty"`
+	PeerDependencies     map[string]string `json:"peerDependencies,omitempty"`
+	Os                   []string          `json:"os,omitempty"`
+	Cpu                  []string          `json:"cpu,omitempty"`
+	Libc                 []string          `json:"libc,omitempty"`
+}
+
+// parseNpmLockfile converts the "packages" section of a v2/v3
+// package-lock.json into a graph. Edges are rebuilt by running Node's module
+// lookup over the recorded node_modules paths. Links, workspaces among them,
+// become the same nodes a "link:" to their directory would.
+func parseNpmLockfile(data []byte, _ PackageJson, _ []Workspace) (Resolved, error) {
+	var lock npmLockfile
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return Resolved{}, fmt.Errorf("invalid %s: %w", NpmLockfileName, err)
+	}
+	if lock.LockfileVersion < 2 || lock.Packages == nil {
+		return Resolved{}, fmt.Errorf("%s v%d is not supported, only v2 and v3", NpmLockfileName, lock.LockfileVersion)
+	}
+	resolved := newResolved()
+	layout := make(Layout)
+	// importers are the directories links point at, workspaces among them,
+	// whose own dependencies sit in their node_modules.
+	importers := make(map[string]string)
+	for _, path := range sortedKeys(lock.Packages) {
+		entry := lock.Packages[path]
+		idx := strings.LastIndex(path, "node_modules/")
+		if idx != -1 && entry.Link && entry.Resolved != "" {
+			name := path[idx+len("node_modules/"):]
+			spec := fileSpec{path: entry.Resolved, link: true}.String()
+			key := PackageKey(name, spec)
+			layout[path] = key
+			importers[entry.Resolved] = key
+			if _, ok := resolved.Packages[key]; !ok {
+				target := lock.Packages[entry.Resolved]
+				resolved.Packages[key] = Deps{
+					Name:                 name,
+					Version:              target.Version,
+					Tarball:              spec,
+					Dependencies:         make(map[string]string),
+					OptionalDependencies: target.OptionalDependencies,
+				}
+			}
+			continue
+		}
+		if idx == -1 || entry.Link || entry.Version == "" {
+			continue
+		}
+		name := entry.Name
+		if name == "" {
+			name = path[idx+len("node_modules/"):]
+		}
+		key := PackageKey(name, entry.Version)
+		layout[path] = key
+		if _, ok := resolved.Packages[key]; ok {
+			continue
+		}
+		resolved.Packages[key] = Deps{
+			Name:                 name,
+			Version:              entry.Version,
+			Tarball:              entry.Resolved,
+			Integrity:            entry.Integrity,
+			Dependencies:         make(map[string]string),
+			OptionalDependencies: entry.OptionalDependencies,
+			PeerDependencies:     entry.PeerDependencies,
+			Os:                   entry.Os,
+			Cpu:                  entry.Cpu,
+			Libc:                 entry.Libc,
+			Optional:             entry.Optional,
+		}
+	}
+	for _, path := range sortedKeys(lock.Packages) {
+		entry := lock.Packages[path]
+		if path == "" {
+			for _, specs := range []map[string]string{entry.Dependencies, entry.DevDependencies, entry.OptionalDependencies} {
+				for name := range specs {
+					if key, ok := layout.lookup("", name); ok {
+						resolved.Dependencies[name] = key
+					}
+				}
+			}
+			continue
+		}
+		key, ok := layout[path]
+		if !ok {
+			key, ok = importers[path]
+		}
+		if !ok {
+			continue
+		}
+		// Only importers record devDependencies, and they install them.
+		for _, specs := range []map[string]string{entry.Dependencies, entry.DevDependencies, entry.OptionalDependencies} {
+			for name := range specs {
+				if depKey, ok := layout.lookup(path, name); ok {
+					resolved.Packages[key].Dependencies[name] = depKey
+				}
+			}
+		}
+		deps := resolved.Packages[key]
+		for name := range entry.PeerDependencies {
+			if depKey, ok := layout.lookup(path, name); ok {
+				if deps.Peers == nil {
+					deps.Peers = make(map[string]string)
+				}
+				deps.Peers[name] = depKey
+			}
+		}
+		resolved.Packages[key] = deps
+	}
+	return resolved, nil
+}
+
+// ExportNpmLockfile renders the graph as a package-lock.json v3 using the
+// same hoisted layout tidy installs. Dependency specs are the exact resolved
+// versions, which npm accepts as satisfied by the locked tree. Packages only
+// reachable through devDependencies, of the project or of a workspace, are
+// marked dev.
+func ExportNpmLockfile(pkgs PackageJson, workspaces []Workspace, resolved Resolved) ([]byte, error) {
+	lock := npmLockfile{
+		Name:            pkgs.Name,
+		Version:         pkgs.Version,
+		LockfileVersion: 3,
+		Requires:        true,
+		Packages: map[string]npmLockPackage{
+			"": {
+				Name:                 pkgs.Name,
+				Version:              pkgs.Version,
+				Workspaces:           workspacePatterns(pkgs),
+				Dependencies:         pkgs.Dependencies,
+				DevDependencies:      pkgs.DevDependencies,
+				OptionalDependencies: pkgs.OptionalDependencies,
+			},
+		},
+	}
+	prod := make(map[string]bool)
+	var pending []string
+	follow := func(edges map[string]string, declared ...map[string]string) {
+		for _, specs := range declared {
+			for name := range specs {
+				if key, ok := edges[name]; ok {
+					pending = append(pending, key)
+				}
+			}
+		}
+	}
+	follow(resolved.Dependencies, pkgs.Dependencies, pkgs.OptionalDependencies)
+	byKey := make(map[string]Workspace, len(workspaces))
+	for _, ws := range workspaces {
+		// Every workspace is linked from the root, as npm installs them.
+		byKey[ws.key()] = ws
+		pending = append(pending, ws.key())
+	}
+	for len(pending) > 0 {
+		key := pending[0]
+		pending = pending[1:]
+		if prod[key] {
+			continue
+		}
+		prod[key] = true
+		deps := resolved.Packages[key]
+		if ws, ok := byKey[key]; ok {
+			follow(deps.Dependencies, ws.Package.Dependencies, ws.Package.OptionalDependencies)
+		} else {
+			for _, depKey := range deps.Dependencies {
+				pending = append(pending, depKey)
+			}
+		}
+		// Peers installed for a package are needed wherever it is.
+		for _, depKey := range deps.Peers {
+			pending = append(pending, depKey)
+		}
+	}
+	layout := Hoist(resolved)
+	for path, key := range layout {
+		deps, ok := resolved.Packages[key]
+		if !ok {
+			continue
+		}
+		entry := npmLockPackage{
+			Version:          deps.Version,
+			Resolved:         deps.Tarball,
+			Integrity:        deps.Integrity,
+			Dev:              !prod[key],
+			Optional:         deps.Optional,
+			PeerDependencies: deps.PeerDependencies,
+			Os:               deps.Os,
+			Cpu:              deps.Cpu,
+			Libc:             deps.Libc,
+		}
+		if installName := path[strings.LastIndex(path, "node_modules/")+len("node_modules/"):]; installName != deps.Name {
+			entry.Name = deps.Name
+		}
+		for name, depKey := range deps.Dependencies {
+			target, ok := resolved.Packages[depKey]
+			if !ok {
+				continue
+			}
+			spec := target.Version
+			switch {
+			case depKey != PackageKey(target.Name, target.Version):
+				spec = target.Tarball
+			case target.Name != name:
+				spec = "npm:" + target.Name + "@" + target.Version
+			}
+			if _, optional := deps.OptionalDependencies[name]; optional {
+				if entry.OptionalDependencies == nil {
+					entry.OptionalDependencies = make(map[string]string)
+				}
+				entry.OptionalDependencies[name] = spec
+				continue
+			}
+			if entry.Dependencies == nil {
+				entry.Dependencies = make(map[string]string)
+			}
+			entry.Dependencies[name] = spec
+		}
+		if f, ok := parseFileSpec(deps.Tarball); ok && !f.tarball {
+			// npm records directories as a link to an entry keyed by their path.
+			lock.Packages[path] = npmLockPackage{Resolved: f.path, Link: true, Dev: entry.Dev}
+			entry.Name, entry.Resolved = deps.Name, ""
+			path = f.path
+		}
+		lock.Packages[path] = entry
+	}
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
@@ -0,0 +1,119 @@
+package internal
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseNpmLockfileWorkspaces(t *testing.T) {
+	data := []byte(`{
+  "name": "root",
+  "lockfileVersion": 3,
+  "packages": {
+    "": {"name": "root", "workspaces": ["packages/*"], "dependencies": {"a": "*", "ms": "^2.1.0"}},
+    "node_modules/a": {"resolved": "packages/a", "link": true},
+    "node_modules/ms": {"version": "2.1.3", "resolved": "https://registry.npmjs.org/ms/-/ms-2.1.3.tgz"},
+    "packages/a": {"name": "a", "version": "1.0.0", "dependencies": {"ms": "^2.0.0"}, "devDependencies": {"debug": "^4.0.0"}},
+    "packages/a/node_modules/ms": {"version": "2.0.0", "resolved": "https://registry.npmjs.org/ms/-/ms-2.0.0.tgz"},
+    "packages/a/node_modules/debug": {"version": "4.3.4", "resolved": "https://registry.npmjs.org/debug/-/debug-4.3.4.tgz", "dependencies": {"ms": "2.1.2"}},
+    "packages/a/node_modules/debug/node_modules/ms": {"version": "2.1.2", "resolved": "https://registry.npmjs.org/ms/-/ms-2.1.2.tgz"}
+  }
+}`)
+	resolved, err := parseNpmLockfile(data, PackageJson{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws := Workspace{Dir: "packages/a", Package: PackageJson{Name: "a"}}.key()
+	if got := resolved.Dependencies["a"]; got != ws {
+		t.Fatalf("root edge to a = %q, want %q", got, ws)
+	}
+	if got := resolved.Dependencies["ms"]; got != "ms@2.1.3" {
+		t.Errorf("root edge to ms = %q, want ms@2.1.3", got)
+	}
+	node, ok := resolved.Packages[ws]
+	if !ok {
+		t.Fatalf("workspace %s is not in the graph", ws)
+	}
+	if node.Version != "1.0.0" || node.Tarball != "link:packages/a" {
+		t.Errorf("workspace node = %+v", node)
+	}
+	want := map[string]string{"ms": "ms@2.0.0", "debug": "debug@4.3.4"}
+	for name, key := range want {
+		if got := node.Dependencies[name]; got != key {
+			t.Errorf("workspace edge to %s = %q, want %q", name, got, key)
+		}
+	}
+	if got := resolved.Packages["debug@4.3.4"].Dependencies["ms"]; got != "ms@2.1.2" {
+		t.Errorf("debug edge to ms = %q, want ms@2.1.2", got)
+	}
+}
+
+func TestExportNpmLockfileDev(t *testing.T) {
+	ws := Workspace{Dir: "packages/w", Package: PackageJson{
+		Name:            "w",
+		Version:         "1.0.0",
+		Dependencies:    map[string]string{"lodash": "^4.0.0"},
+		DevDependencies: map[string]string{"tsx": "^4.0.0"},
+	}}
+	pkgs := PackageJson{
+		Name:            "root",
+		Dependencies:    map[string]string{"a": "^1.0.0"},
+		DevDependencies: map[string]string{"jest": "^29.0.0"},
+	}
+	resolved := newResolved()
+	add := func(key string, deps Deps) {
+		if deps.Dependencies == nil {
+			deps.Dependencies = make(map[string]string)
+		}
+		resolved.Packages[key] = deps
+	}
+	add("a@1.0.0", Deps{Name: "a", Version: "1.0.0", Peers: map[string]string{"react": "react@18.0.0"}})
+	add("react@18.0.0", Deps{Name: "react", Version: "18.0.0"})
+	add("jest@29.0.0", Deps{Name: "jest", Version: "29.0.0", Dependencies: map[string]string{"chalk": "chalk@4.0.0"}})
+	add("chalk@4.0.0", Deps{Name: "chalk", Version: "4.0.0"})
+	add(ws.key(), Deps{Name: "w", Version: "1.0.0", Tarball: ws.spec(), Dependencies: map[string]string{"lodash": "lodash@4.0.0", "tsx": "tsx@4.0.0"}})
+	add("lodash@4.0.0", Deps{Name: "lodash", Version: "4.0.0"})
+	add("tsx@4.0.0", Deps{Name: "tsx", Version: "4.0.0"})
+	for name, key := range map[string]string{"a": "a@1.0.0", "react": "react@18.0.0", "jest": "jest@29.0.0", "w": ws.key()} {
+		resolved.Dependencies[name] = key
+	}
+
+	data, err := ExportNpmLockfile(pkgs, []Workspace{ws}, resolved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lock npmLockfile
+	if err := json.Unmarshal(data, &lock); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{
+		"a":      false,
+		"react":  false,
+		"w":      false,
+		"lodash": false,
+		"jest":   true,
+		"chalk":  true,
+		"tsx":    true,
+	}
+	seen := make(map[string]bool)
+	for path, entry := range lock.Packages {
+		idx := strings.LastIndex(path, "node_modules/")
+		if idx == -1 {
+			continue
+		}
+		name := path[idx+len("node_modules/"):]
+		seen[name] = true
+		if entry.Dev != want[name] {
+			t.Errorf("%s dev = %v, want %v", path, entry.Dev, want[name])
+		}
+	}
+	for name := range want {
+		if !seen[name] {
+			t.Errorf("%s is missing from the export", name)
+		}
+	}
+	if lock.Packages["packages/w"].Dev {
+		t.Errorf("workspace packages/w is marked dev")
+	}
+}
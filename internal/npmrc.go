@@ -0,0 +1,203 @@
+package internal
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const npmrcName = ".npmrc"
+
+// npmConfig is the merged configuration of the .npmrc files and NPM_CONFIG_*
+// environment variables. Like npm, the environment wins over the project's
+// .npmrc, which wins over the user's and then the global one.
+type npmConfig map[string]string
+
+var (
+	npmrcOnce sync.Once
+	npmrc     npmConfig
+)
+
+// config returns the configuration for the project in the working directory,
+// read once per run.
+func config() npmConfig {
+	npmrcOnce.Do(func() {
+		root, _ := os.Getwd()
+		npmrc = loadNpmConfig(root)
+	})
+	return npmrc
+}
+
+func loadNpmConfig(root string) npmConfig {
+	env := envConfig()
+	cfg := make(npmConfig)
+	files := []string{globalNpmrc(env), userNpmrc(env), filepath.Join(root, npmrcName)}
+	for _, file := range files {
+		if file == "" {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		for key, value := range parseNpmrc(string(data)) {
+			cfg[key] = value
+		}
+	}
+	for key, value := range env {
+		cfg[key] = value
+	}
+	return cfg
+}
+
+// envConfig reads npm_config_* variables, in any case, the way npm does:
+// "NPM_CONFIG_STRICT_SSL" sets "strict-ssl".
+func envConfig() npmConfig {
+	cfg := make(npmConfig)
+	for _, kv := range os.Environ() {
+		key, value, _ := strings.Cut(kv, "=")
+		if len(key) <= len("npm_config_") || !strings.EqualFold(key[:len("npm_config_")], "npm_config_") {
+			continue
+		}
+		key = strings.ToLower(key[len("npm_config_"):])
+		cfg[key[:1]+strings.ReplaceAll(key[1:], "_", "-")] = value
+	}
+	return cfg
+}
+
+func userNpmrc(env npmConfig) string {
+	if file := env["userconfig"]; file != "" {
+		return file
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, npmrcName)
+}
+
+// globalNpmrc is {prefix}/etc/npmrc, the prefix being the one of the Node
+// installation on the PATH unless configured.
+func globalNpmrc(env npmConfig) string {
+	if file := env["globalconfig"]; file != "" {
+		return file
+	}
+	prefix := env["prefix"]
+	if prefix == "" {
+		node, err := exec.LookPath("node")
+		if err != nil {
+			return ""
+		}
+		if resolved, err := filepath.EvalSymlinks(node); err == nil {
+			node = resolved
+		}
+		prefix = filepath.Dir(filepath.Dir(node))
+	}
+	return filepath.Join(prefix, "etc", "npmrc")
+}
+
+// parseNpmrc reads the ini format of .npmrc, expanding ${VAR} references in
+// keys and values. Array keys (key[]=) collect their values one per line;
+// sections are not used by tidy.
+func parseNpmrc(data string) npmConfig {
+	cfg := make(npmConfig)
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' || line[0] == ';' || line[0] == '[' {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			value = "true"
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		key, value = expandEnv(key), expandEnv(value)
+		if strings.HasSuffix(key, "[]") && cfg[key] != "" {
+			value = cfg[key] + "\n" + value
+		}
+		cfg[key] = value
+	}
+	return cfg
+}
+
+var envReference = regexp.MustCompile(`\$\{([^${}?]+)(\?)?\}`)
+
+// expandEnv replaces ${VAR} with its value. npm refuses to run when VAR is
+// unset; tidy leaves the reference as is, unless written ${VAR?}.
+func expandEnv(s string) string {
+	return envReference.ReplaceAllStringFunc(s, func(ref string) string {
+		m := envReference.FindStringSubmatch(ref)
+		if value, ok := os.LookupEnv(m[1]); ok {
+			return value
+		}
+		if m[2] == "?" {
+			return ""
+		}
+		return ref
+	})
+}
+
+// SetConfig overrides a setting for this run, as command-line flags do in
+// npm. It must be called before any registry access.
+func SetConfig(key, value string) {
+	config()[key] = value
+}
+
+// Offline reports whether the network is off limits: everything has to come
+// from the packument cache and the store, or from the mirror when installing
+// from one.
+func Offline() bool {
+	return config()["offline"] == "true" || mirrorDir() != ""
+}
+
+// SavePrefix is the range prefix added dependencies are saved with, from
+// npm's save-exact and save-prefix settings.
+func SavePrefix() string {
+	cfg := config()
+	if cfg["save-exact"] == "true" {
+		return ""
+	}
+	if prefix, ok := cfg["save-prefix"]; ok {
+		return prefix
+	}
+	return "^"
+}
+
+// preferOffline makes cached packuments good enough, however old, as long as
+// they have a version for the range asked for.
+func preferOffline() bool {
+	return config()["prefer-offline"] == "true"
+}
+
+// registryFor returns the registry name is fetched from: the one configured
+// for its scope if any, the default registry otherwise.
+func registryFor(name string) string {
+	cfg := config()
+	if strings.HasPrefix(name, "@") {
+		scope, _, _ := strings.Cut(name, "/")
+		if registry := cfg[scope+":registry"]; registry != "" {
+			return strings.TrimSuffix(registry, "/")
+		}
+	}
+	if registry := cfg["registry"]; registry != "" {
+		return strings.TrimSuffix(registry, "/")
+	}
+	return REGISTRY_URL
+}
+
+// registryTarball points a tarball URL of the public registry, as recorded
+// in lockfiles made elsewhere, at the registry configured for name. npm does
+// the same through its replace-registry-host setting.
+func registryTarball(name, tarball string) string {
+	registry := registryFor(name)
+	if rest, ok := strings.CutPrefix(tarball, REGISTRY_URL+"/"); ok && registry != REGISTRY_URL {
+		return registry + "/" + rest
+	}
+	return tarball
+}
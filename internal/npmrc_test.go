@@ -0,0 +1,30 @@
+package internal
+
+import "testing"
+
+func TestSavePrefix(t *testing.T) {
+	tests := []struct {
+		npmrc string
+		// flags are applied after .npmrc, as the command line does.
+		flags map[string]string
+		want  string
+	}{
+		{"", nil, "^"},
+		{"save-prefix=~\n", nil, "~"},
+		{"save-prefix=''\n", nil, ""},
+		{"save-exact=true\n", nil, ""},
+		{"save-exact\nsave-prefix=~\n", nil, ""},
+		{"save-prefix=~\n", map[string]string{"save-prefix": "^"}, "^"},
+		{"save-exact=true\n", map[string]string{"save-exact": "false"}, "^"},
+		{"", map[string]string{"save-exact": "true"}, ""},
+	}
+	for _, tt := range tests {
+		useNpmrc(t, tt.npmrc)
+		for key, value := range tt.flags {
+			SetConfig(key, value)
+		}
+		if got := SavePrefix(); got != tt.want {
+			t.Errorf("SavePrefix() with %q and flags %v = %q, want %q", tt.npmrc, tt.flags, got, tt.want)
+		}
+	}
+}
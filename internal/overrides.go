@@ -0,0 +1,170 @@
+package internal
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/chann44/tidy/internal/semver"
+)
+
+type overrideSelector struct {
+	name string
+	rng  string
+	// adjacent requires the selector to match the package right after the
+	// previous one in the dependency path (Yarn's "a/b" as opposed to "a/**/b").
+	adjacent bool
+}
+
+// overrideRule forces edges to target, requested from a package somewhere
+// below the parents chain, to spec instead of the range their dependent asked
+// for.
+type overrideRule struct {
+	parents []overrideSelector
+	target  overrideSelector
+	spec    string
+}
+
+func (s overrideSelector) matches(name, version string) bool {
+	if s.name != name {
+		return false
+	}
+	return s.rng == "" || semver.Satisfies(version, s.rng)
+}
+
+func (s overrideSelector) String() string {
+	if s.rng == "" {
+		return s.name
+	}
+	return s.name + "@" + s.rng
+}
+
+func (r overrideRule) String() string {
+	var sb strings.Builder
+	for _, sel := range append(r.parents, r.target) {
+		if sb.Len() > 0 {
+			if sel.adjacent {
+				sb.WriteString(" > ")
+			} else {
+				sb.WriteString(" > ** > ")
+			}
+		}
+		sb.WriteString(sel.String())
+	}
+	return sb.String()
+}
+
+// matchesPath reports whether the rule's parents appear, in order, among
+// the package keys on path.
+func (r overrideRule) matchesPath(path []string) bool {
+	var match func(sel, from int) bool
+	match = func(sel, from int) bool {
+		if sel == len(r.parents) {
+			return !r.target.adjacent || len(r.parents) == 0 || from == len(path)
+		}
+		for i := from; i < len(path); i++ {
+			if r.parents[sel].adjacent && sel > 0 && i != from {
+				break
+			}
+			name, version := splitDescriptor(path[i])
+			if r.parents[sel].matches(name, version) && match(sel+1, i+1) {
+				return true
+			}
+		}
+		return false
+	}
+	return match(0, 0)
+}
+
+// parseOverrides collects npm "overrides" and Yarn "resolutions" into rules
+// ordered most specific first.
+func parseOverrides(pkgs PackageJson) []overrideRule {
+	rootSpecs := rootSpecifiers(pkgs)
+	rules := parseNpmOverrides(pkgs.Overrides, nil, rootSpecs)
+	for _, key := range sortedKeys(pkgs.Resolutions) {
+		rules = append(rules, parseYarnResolution(key, pkgs.Resolutions[key]))
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		return len(rules[i].parents) > len(rules[j].parents)
+	})
+	return rules
+}
+
+func parseNpmOverrides(overrides map[string]interface{}, parents []overrideSelector, rootSpecs map[string]string) []overrideRule {
+	var rules []overrideRule
+	for _, key := range sortedKeys(overrides) {
+		name, rng := splitDescriptor(key)
+		target := overrideSelector{name: name, rng: rng}
+		switch value := overrides[key].(type) {
+		case string:
+			rules = append(rules, overrideRule{parents: parents, target: target, spec: overrideReference(value, rootSpecs)})
+		case map[string]interface{}:
+			if self, ok := value["."].(string); ok {
+				rules = append(rules, overrideRule{parents: parents, target: target, spec: overrideReference(self, rootSpecs)})
+			}
+			nested := make(map[string]interface{}, len(value))
+			for k, v := range value {
+				if k != "." {
+					nested[k] = v
+				}
+			}
+			chain := append(append([]overrideSelector(nil), parents...), target)
+			rules = append(rules, parseNpmOverrides(nested, chain, rootSpecs)...)
+		}
+	}
+	return rules
+}
+
+// overrideReference expands npm's "$name", which reuses the spec the root
+// package.json declares for name.
+func overrideReference(spec string, rootSpecs map[string]string) string {
+	if strings.HasPrefix(spec, "$") {
+		if root, ok := rootSpecs[spec[1:]]; ok {
+			return root
+		}
+	}
+	return spec
+}
+
+// parseYarnResolution handles "name", "**/name", "parent/name" and
+// "parent/**/name" keys, scoped names included.
+func parseYarnResolution(key, spec string) overrideRule {
+	var names []string
+	segments := strings.Split(key, "/")
+	for i := 0; i < len(segments); i++ {
+		if strings.HasPrefix(segments[i], "@") && i+1 < len(segments) {
+			names = append(names, segments[i]+"/"+segments[i+1])
+			i++
+			continue
+		}
+		names = append(names, segments[i])
+	}
+	var selectors []overrideSelector
+	adjacent := false
+	for _, name := range names {
+		if name == "**" {
+			adjacent = false
+			continue
+		}
+		selectors = append(selectors, overrideSelector{name: name, adjacent: adjacent})
+		adjacent = true
+	}
+	rule := overrideRule{spec: spec}
+	if len(selectors) > 0 {
+		rule.target = selectors[len(selectors)-1]
+		rule.parents = selectors[:len(selectors)-1]
+	}
+	return rule
+}
+
+// overrideSpecs is the form stored in tidy.lock so that editing overrides
+// invalidates the lock.
+func overrideSpecs(rules []overrideRule) map[string]string {
+	if len(rules) == 0 {
+		return nil
+	}
+	specs := make(map[string]string, len(rules))
+	for _, rule := range rules {
+		specs[rule.String()] = rule.spec
+	}
+	return specs
+}
@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func overrideTestPackage() PackageJson {
+	return PackageJson{
+		Dependencies: map[string]string{"x": "^9.0.0"},
+		Overrides: map[string]interface{}{
+			"foo":        "1.0.0",
+			"@scope/bar": "2.0.0",
+			"a":          map[string]interface{}{"b": "3.0.0"},
+			"c@^2.0.0":   map[string]interface{}{"d": "4.0.0"},
+			"e":          map[string]interface{}{".": "5.0.0", "f": "6.0.0"},
+			"@scope/g":   map[string]interface{}{"@scope/h": "7.0.0"},
+			"x":          "$x",
+		},
+		Resolutions: map[string]string{
+			"**/y":         "8.0.0",
+			"p/q":          "10.0.0",
+			"@s/p/**/@s/q": "11.0.0",
+		},
+	}
+}
+
+func TestOverride(t *testing.T) {
+	r := newResolver()
+	r.overrides = parseOverrides(overrideTestPackage())
+	tests := []struct {
+		name string
+		path []string
+		want string
+	}{
+		{"foo", []string{"z@1.0.0"}, "1.0.0"},
+		{"foo", nil, "^0.1.0"},
+		{"@scope/bar", []string{"z@1.0.0"}, "2.0.0"},
+		{"b", []string{"a@1.0.0"}, "3.0.0"},
+		{"b", []string{"a@1.0.0", "m@1.0.0"}, "3.0.0"},
+		{"b", []string{"z@1.0.0"}, "^0.1.0"},
+		{"d", []string{"c@2.1.0"}, "4.0.0"},
+		{"d", []string{"c@1.0.0"}, "^0.1.0"},
+		{"e", []string{"z@1.0.0"}, "5.0.0"},
+		{"f", []string{"z@1.0.0", "e@5.0.0"}, "6.0.0"},
+		{"f", []string{"z@1.0.0"}, "^0.1.0"},
+		{"@scope/h", []string{"@scope/g@1.0.0"}, "7.0.0"},
+		{"@scope/h", []string{"@scope/other@1.0.0"}, "^0.1.0"},
+		{"x", []string{"z@1.0.0"}, "^9.0.0"},
+		{"y", []string{"a@1.0.0", "b@3.0.0"}, "8.0.0"},
+		{"q", []string{"p@1.0.0"}, "10.0.0"},
+		{"q", []string{"p@1.0.0", "m@1.0.0"}, "^0.1.0"},
+		{"@s/q", []string{"@s/p@1.0.0", "m@1.0.0"}, "11.0.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+" under "+strings.Join(tt.path, " > "), func(t *testing.T) {
+			edge := pkg{name: tt.name, vesrion: "^0.1.0", path: tt.path}
+			if len(tt.path) > 0 {
+				edge.parent = tt.path[len(tt.path)-1]
+			}
+			if got := r.override(edge); got != tt.want {
+				t.Errorf("override = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOverridesInvalidateLock(t *testing.T) {
+	pkgs := overrideTestPackage()
+	lock := NewLockfile(pkgs, nil, newResolved())
+	if problems := lock.Diff(pkgs, nil); len(problems) != 0 {
+		t.Fatalf("unchanged package.json: %v", problems)
+	}
+	tests := []struct {
+		name   string
+		change func(*PackageJson)
+	}{
+		{"changed npm override", func(p *PackageJson) { p.Overrides["foo"] = "1.0.1" }},
+		{"changed nested override", func(p *PackageJson) { p.Overrides["a"] = map[string]interface{}{"b": "3.0.1"} }},
+		{"added override", func(p *PackageJson) { p.Overrides["new"] = "1.0.0" }},
+		{"removed override", func(p *PackageJson) { delete(p.Overrides, "@scope/bar") }},
+		{"changed resolution", func(p *PackageJson) { p.Resolutions["p/q"] = "10.0.1" }},
+		{"changed $ reference", func(p *PackageJson) { p.Dependencies["x"] = "^9.1.0" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed := overrideTestPackage()
+			tt.change(&changed)
+			problems := lock.Diff(changed, nil)
+			found := false
+			for _, problem := range problems {
+				found = found || strings.HasPrefix(problem, "override ")
+			}
+			if !found {
+				t.Errorf("no override problem reported: %v", problems)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// neverPacked are skipped at any depth, like npm pack does.
+var neverPacked = map[string]bool{
+	".git": true, ".svn": true, ".hg": true, "CVS": true, "node_modules": true,
+	".npmrc": true, ".npmignore": true, ".gitignore": true, ".DS_Store": true, "npm-debug.log": true,
+	"package-lock.json": true, "yarn.lock": true, "pnpm-lock.yaml": true, LockfileName: true,
+}
+
+// alwaysPacked are top-level files included even when "files" or an ignore
+// file would leave them out.
+var alwaysPacked = []string{"package.json", "README*", "LICENSE*", "LICENCE*", "CHANGELOG*"}
+
+// packDir copies the files npm pack would publish from src into destDir:
+// those listed in package.json "files" if present, otherwise everything not
+// excluded by .npmignore (or .gitignore when there is none).
+func packDir(src, destDir string) error {
+	files, err := packFiles(src)
+	if err != nil {
+		return err
+	}
+	tempDir := destDir + ".tmp"
+	os.RemoveAll(tempDir)
+	defer os.RemoveAll(tempDir)
+	for _, rel := range files {
+		target := filepath.Join(tempDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		source := filepath.Join(src, filepath.FromSlash(rel))
+		if err := CopyFile(source, target); err != nil {
+			return err
+		}
+		if info, err := os.Stat(source); err == nil {
+			os.Chmod(target, info.Mode().Perm())
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(destDir), 0755); err != nil {
+		return err
+	}
+	os.RemoveAll(destDir)
+	return os.Rename(tempDir, destDir)
+}
+
+func packFiles(src string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(src, "package.json"))
+	if err != nil {
+		return nil, fmt.Errorf("%s has no package.json", src)
+	}
+	var pkg struct {
+		Main  string   `json:"main"`
+		Files []string `json:"files"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil, fmt.Errorf("invalid package.json in %s: %w", src, err)
+	}
+	main := ""
+	if pkg.Main != "" {
+		main = strings.TrimPrefix(path.Clean(pkg.Main), "./")
+	}
+	ignore := readIgnoreFile(filepath.Join(src, ".npmignore"))
+	if ignore == nil {
+		ignore = readIgnoreFile(filepath.Join(src, ".gitignore"))
+	}
+	var files []string
+	err = filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if neverPacked[d.Name()] {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+		included := false
+		switch {
+		case !strings.Contains(rel, "/") && matchesAnyPackPattern(alwaysPacked, rel), rel == main:
+			included = true
+		case pkg.Files != nil:
+			included = matchesAnyPackPattern(pkg.Files, rel)
+		default:
+			included = !ignored(ignore, rel)
+		}
+		if included {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	return files, err
+}
+
+func readIgnoreFile(file string) []string {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+	patterns := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			patterns = append(patterns, line)
+		}
+	}
+	return patterns
+}
+
+// ignored applies gitignore-style patterns in order; the last match wins and
+// a leading ! re-includes.
+func ignored(patterns []string, rel string) bool {
+	result := false
+	for _, pattern := range patterns {
+		negate := strings.HasPrefix(pattern, "!")
+		if matchPackPattern(strings.TrimPrefix(pattern, "!"), rel) {
+			result = !negate
+		}
+	}
+	return result
+}
+
+func matchesAnyPackPattern(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if matchPackPattern(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPackPattern matches a gitignore-style pattern against a file or any of
+// its parent directories. Patterns containing a slash are anchored to the
+// package root; others match a single path segment at any depth.
+func matchPackPattern(pattern, rel string) bool {
+	pattern = strings.TrimPrefix(pattern, "./")
+	dirOnly := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimSuffix(strings.TrimSuffix(pattern, "/"), "/**")
+	anchored := strings.Contains(pattern, "/")
+	for strings.HasPrefix(pattern, "**/") {
+		pattern = strings.TrimPrefix(pattern, "**/")
+		anchored = strings.Contains(pattern, "/")
+	}
+	pattern = strings.TrimPrefix(pattern, "/")
+	parts := strings.Split(rel, "/")
+	for i := 1; i <= len(parts); i++ {
+		if dirOnly && i == len(parts) {
+			break
+		}
+		candidate := parts[i-1]
+		if anchored {
+			candidate = strings.Join(parts[:i], "/")
+		}
+		if ok, _ := path.Match(pattern, candidate); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package internal
+
+import (
+	"fmt"
+
+	"github.com/chann44/tidy/internal/semver"
+)
+
+// dependents maps every package key to the keys of the packages depending
+// on it, "" standing for the project itself.
+func dependents(res Resolved) map[string][]string {
+	byKey := make(map[string][]string)
+	for _, name := range sortedKeys(res.Dependencies) {
+		key := res.Dependencies[name]
+		byKey[key] = append(byKey[key], "")
+	}
+	for _, parent := range sortedKeys(res.Packages) {
+		deps := res.Packages[parent]
+		for _, name := range sortedKeys(deps.Dependencies) {
+			key := deps.Dependencies[name]
+			byKey[key] = append(byKey[key], parent)
+		}
+	}
+	return byKey
+}
+
+// peerProvider finds the package a peer dependency is satisfied by: one its
+// dependents depend on, or failing that one the project depends on.
+func peerProvider(res Resolved, name string, parents []string) (string, bool) {
+	for _, parent := range parents {
+		edges := res.Dependencies
+		if parent != "" {
+			edges = res.Packages[parent].Dependencies
+		}
+		if key, ok := edges[name]; ok {
+			return key, true
+		}
+	}
+	key, ok := res.Dependencies[name]
+	return key, ok
+}
+
+// linkPeers records in Peers the provider of every peer dependency. A peer
+// the package also depends on is provided by that dependency.
+// Like npm 7, a required peer nobody provides is installed as a dependency
+// of the package's dependent; linkPeers reports whether it scheduled any,
+// in which case it has to run again once they are resolved. tried keeps a
+// peer that failed to resolve from being scheduled forever.
+func (r *resolver) linkPeers(tried map[string]bool) bool {
+	byKey := dependents(r.resolved)
+	var missing []pkg
+	for _, key := range sortedKeys(r.resolved.Packages) {
+		deps := r.resolved.Packages[key]
+		for _, name := range sortedKeys(deps.PeerDependencies) {
+			if _, ok := deps.Peers[name]; ok {
+				continue
+			}
+			provider, ok := deps.Dependencies[name]
+			if !ok {
+				provider, ok = peerProvider(r.resolved, name, byKey[key])
+			}
+			if ok {
+				if deps.Peers == nil {
+					deps.Peers = make(map[string]string)
+					r.resolved.Packages[key] = deps
+				}
+				deps.Peers[name] = provider
+				continue
+			}
+			if deps.PeerDependenciesMeta[name].Optional {
+				continue
+			}
+			parent := ""
+			if parents := byKey[key]; len(parents) > 0 {
+				parent = parents[0]
+			}
+			if tried[parent+" "+name] {
+				continue
+			}
+			tried[parent+" "+name] = true
+			edge := pkg{name: name, vesrion: deps.PeerDependencies[name], parent: parent}
+			if parent != "" {
+				edge.path = []string{parent}
+			}
+			missing = append(missing, edge)
+		}
+	}
+	for _, edge := range missing {
+		r.schedule(edge)
+	}
+	return len(missing) > 0
+}
+
+// PeerWarnings describes every peer dependency in the graph that is missing
+// or provided by a version outside the range its dependent asked for.
+func PeerWarnings(res Resolved) []string {
+	var warnings []string
+	for _, key := range sortedKeys(res.Packages) {
+		deps := res.Packages[key]
+		for _, name := range sortedKeys(deps.PeerDependencies) {
+			rng := deps.PeerDependencies[name]
+			provider, ok := res.Packages[deps.Peers[name]]
+			switch {
+			case !ok && !deps.PeerDependenciesMeta[name].Optional:
+				warnings = append(warnings, fmt.Sprintf("%s@%s needs peer %s@%s, which is not installed", deps.Name, deps.Version, name, rng))
+			case ok && !semver.Satisfies(provider.Version, rng):
+				warnings = append(warnings, fmt.Sprintf("%s@%s needs peer %s@%s, but %s@%s is installed", deps.Name, deps.Version, name, rng, provider.Name, provider.Version))
+			}
+		}
+	}
+	return warnings
+}
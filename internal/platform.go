@@ -0,0 +1,163 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// platformList is an os, cpu or libc field of package.json, which may be a
+// single string as well as an array.
+type platformList []string
+
+func (l *platformList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*l = platformList{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// Platform is what the os, cpu and libc fields of packages are checked
+// against, with the names Node uses for process.platform and process.arch.
+// Libc only matters on Linux and is left empty when unknown.
+type Platform struct {
+	OS   string
+	CPU  string
+	Libc string
+}
+
+var nodeOS = map[string]string{
+	"windows": "win32",
+	"solaris": "sunos",
+	"illumos": "sunos",
+}
+
+var nodeArch = map[string]string{
+	"amd64":    "x64",
+	"386":      "ia32",
+	"ppc64le":  "ppc64",
+	"mips64le": "mips64el",
+	"mipsle":   "mipsel",
+	"loong64":  "loong64",
+}
+
+// HostPlatform describes the machine tidy runs on.
+func HostPlatform() Platform {
+	p := Platform{OS: runtime.GOOS, CPU: runtime.GOARCH}
+	if name, ok := nodeOS[p.OS]; ok {
+		p.OS = name
+	}
+	if name, ok := nodeArch[p.CPU]; ok {
+		p.CPU = name
+	}
+	if p.OS == "linux" {
+		p.Libc = "glibc"
+		if musl, _ := filepath.Glob("/lib/ld-musl-*"); len(musl) > 0 {
+			p.Libc = "musl"
+		}
+	}
+	return p
+}
+
+func (p Platform) String() string {
+	if p.Libc == "" {
+		return p.OS + "-" + p.CPU
+	}
+	return p.OS + "-" + p.CPU + "-" + p.Libc
+}
+
+// Supports reports whether deps can be installed on p.
+func (p Platform) Supports(deps Deps) bool {
+	if !platformAllows(deps.Os, p.OS) || !platformAllows(deps.Cpu, p.CPU) {
+		return false
+	}
+	return p.OS != "linux" || p.Libc == "" || platformAllows(deps.Libc, p.Libc)
+}
+
+// platformAllows follows npm: an empty list allows everything, "!value"
+// excludes value, and a list made only of exclusions allows the rest.
+func platformAllows(list []string, value string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	onlyExclusions := true
+	for _, item := range list {
+		if excluded, ok := strings.CutPrefix(item, "!"); ok {
+			if excluded == value {
+				return false
+			}
+			continue
+		}
+		onlyExclusions = false
+		if item == value || item == "any" {
+			return true
+		}
+	}
+	return onlyExclusions
+}
+
+// ForPlatform returns the graph to install on p: optional packages that do
+// not support it are dropped together with the edges to them, and listed in
+// skipped. A required package that does not support p is an error.
+func ForPlatform(res Resolved, p Platform) (Resolved, []string, error) {
+	filtered := newResolved()
+	var skipped []string
+	for _, key := range sortedKeys(res.Packages) {
+		deps := res.Packages[key]
+		if p.Supports(deps) {
+			filtered.Packages[key] = deps
+			continue
+		}
+		if !deps.Optional {
+			return Resolved{}, nil, fmt.Errorf("%s@%s does not support %s (%s)", deps.Name, deps.Version, p, platformConstraints(deps))
+		}
+		skipped = append(skipped, PackageKey(deps.Name, deps.Version))
+	}
+	for name, key := range res.Dependencies {
+		if _, ok := filtered.Packages[key]; ok {
+			filtered.Dependencies[name] = key
+		}
+	}
+	for key, deps := range filtered.Packages {
+		edges := make(map[string]string, len(deps.Dependencies))
+		for name, depKey := range deps.Dependencies {
+			if _, ok := filtered.Packages[depKey]; ok {
+				edges[name] = depKey
+			}
+		}
+		deps.Dependencies = edges
+		if deps.Peers != nil {
+			peers := make(map[string]string, len(deps.Peers))
+			for name, depKey := range deps.Peers {
+				if _, ok := filtered.Packages[depKey]; ok {
+					peers[name] = depKey
+				}
+			}
+			deps.Peers = peers
+		}
+		filtered.Packages[key] = deps
+	}
+	return filtered, skipped, nil
+}
+
+func platformConstraints(deps Deps) string {
+	var constraints []string
+	for _, field := range []struct {
+		name string
+		list []string
+	}{{"os", deps.Os}, {"cpu", deps.Cpu}, {"libc", deps.Libc}} {
+		if len(field.list) > 0 {
+			constraints = append(constraints, field.name+": "+strings.Join(field.list, ","))
+		}
+	}
+	return strings.Join(constraints, ", ")
+}
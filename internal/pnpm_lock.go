@@ -0,0 +1,215 @@
+package internal
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/chann44/tidy/internal/semver"
+
+	"gopkg.in/yaml.v3"
+)
+
+const PnpmLockfileName = "pnpm-lock.yaml"
+
+type pnpmLockfile struct {
+	LockfileVersion string                  `yaml:"lockfileVersion"`
+	Importers       map[string]pnpmImporter `yaml:"importers"`
+	pnpmImporter    `yaml:",inline"`
+	Packages        map[string]pnpmPackage `yaml:"packages"`
+	Snapshots       map[string]pnpmPackage `yaml:"snapshots"`
+}
+
+type pnpmImporter struct {
+	Dependencies         map[string]pnpmDependency `yaml:"dependencies"`
+	DevDependencies      map[string]pnpmDependency `yaml:"devDependencies"`
+	OptionalDependencies map[string]pnpmDependency `yaml:"optionalDependencies"`
+}
+
+type pnpmDependency struct {
+	Specifier string `yaml:"specifier"`
+	Version   string `yaml:"version"`
+}
+
+type pnpmPackage struct {
+	Resolution struct {
+		Integrity string `yaml:"integrity"`
+		Tarball   string `yaml:"tarball"`
+		Type      string `yaml:"type"`
+		Repo      string `yaml:"repo"`
+		Commit    string `yaml:"commit"`
+	} `yaml:"resolution"`
+	Name                 string            `yaml:"name"`
+	Version              string            `yaml:"version"`
+	Dependencies         map[string]string `yaml:"dependencies"`
+	OptionalDependencies map[string]string `yaml:"optionalDependencies"`
+}
+
+// parsePnpmLockfile reads lockfileVersion 6 and 9. Version 6 keeps metadata
+// and edges together under "packages" keyed "/name@version(peers)"; version 9
+// splits them into "packages" and "snapshots" keyed "name@version(peers)".
+// Peer-suffixed variants of a package collapse into a single node. Importers
+// other than the root are the workspaces, whose nodes are keyed like the
+// "link:" to their directory.
+func parsePnpmLockfile(data []byte, _ PackageJson, workspaces []Workspace) (Resolved, error) {
+	var lock pnpmLockfile
+	if err := yaml.Unmarshal(data, &lock); err != nil {
+		return Resolved{}, fmt.Errorf("invalid %s: %w", PnpmLockfileName, err)
+	}
+	major, _, _ := strings.Cut(lock.LockfileVersion, ".")
+	if major != "6" && major != "9" {
+		return Resolved{}, fmt.Errorf("%s lockfileVersion %s is not supported, only 6 and 9", PnpmLockfileName, lock.LockfileVersion)
+	}
+	snapshots := lock.Snapshots
+	if major == "6" {
+		snapshots = lock.Packages
+	}
+	resolved := newResolved()
+	// ids maps package ids without their peer suffix to graph keys.
+	ids := make(map[string]string)
+	for _, id := range sortedKeys(lock.Packages) {
+		meta := lock.Packages[id]
+		name, version := parsePnpmPackageId(id)
+		if meta.Name != "" {
+			name = meta.Name
+		}
+		if meta.Version != "" {
+			version = meta.Version
+		}
+		if name == "" || version == "" {
+			continue
+		}
+		key, tarball, ok := pnpmPackageSource(id, name, version, meta)
+		if !ok {
+			continue
+		}
+		ids[pnpmPackageId(id)] = key
+		if _, ok := resolved.Packages[key]; ok {
+			continue
+		}
+		resolved.Packages[key] = Deps{
+			Name:         name,
+			Version:      version,
+			Tarball:      tarball,
+			Integrity:    meta.Resolution.Integrity,
+			Dependencies: make(map[string]string),
+		}
+	}
+	for _, id := range sortedKeys(snapshots) {
+		snapshot := snapshots[id]
+		deps, ok := resolved.Packages[ids[pnpmPackageId(id)]]
+		if !ok {
+			continue
+		}
+		for _, edges := range []map[string]string{snapshot.Dependencies, snapshot.OptionalDependencies} {
+			for depName, ref := range edges {
+				if key, ok := pnpmReferenceKey(ids, depName, ref); ok {
+					deps.Dependencies[depName] = key
+				}
+			}
+		}
+	}
+	importers := lock.Importers
+	if importers == nil {
+		importers = map[string]pnpmImporter{".": lock.pnpmImporter}
+	}
+	byDir := make(map[string]Workspace, len(workspaces))
+	for _, ws := range workspaces {
+		byDir[ws.Dir] = ws
+	}
+	for _, dir := range sortedKeys(importers) {
+		edges := resolved.Dependencies
+		if dir != "." {
+			ws, ok := byDir[dir]
+			if !ok {
+				continue
+			}
+			key := ws.key()
+			resolved.Packages[key] = Deps{
+				Name:                 ws.Package.Name,
+				Version:              ws.Package.Version,
+				Tarball:              ws.spec(),
+				Dependencies:         make(map[string]string),
+				OptionalDependencies: ws.Package.OptionalDependencies,
+			}
+			edges = resolved.Packages[key].Dependencies
+		}
+		importer := importers[dir]
+		for _, deps := range []map[string]pnpmDependency{importer.Dependencies, importer.DevDependencies, importer.OptionalDependencies} {
+			for name, dep := range deps {
+				if target, ok := strings.CutPrefix(dep.Version, "link:"); ok {
+					if ws, ok := byDir[path.Join(dir, target)]; ok {
+						edges[name] = ws.key()
+					}
+					continue
+				}
+				if key, ok := pnpmReferenceKey(ids, name, dep.Version); ok {
+					edges[name] = key
+				}
+			}
+		}
+	}
+	return resolved, nil
+}
+
+// pnpmPackageSource returns the graph key and tarball of a package. Git
+// repositories, pinned to a commit, and tarball URLs are keyed by their source
+// like the resolver keys them; registry packages by name@version.
+func pnpmPackageSource(id, name, version string, meta pnpmPackage) (string, string, bool) {
+	res := meta.Resolution
+	switch {
+	case res.Type == "git":
+		source, ok := gitSource("git+" + strings.TrimPrefix(res.Repo, "git+") + "#" + res.Commit)
+		if !ok {
+			return "", "", false
+		}
+		return PackageKey(name, source), source, true
+	case res.Type == "directory":
+		return "", "", false
+	case res.Tarball != "":
+		if source, ok := gitSource(res.Tarball); ok {
+			return PackageKey(name, source), source, true
+		}
+		if _, idVersion := parsePnpmPackageId(id); !semver.Valid(idVersion) {
+			// Not from a registry, so the id holds the tarball's URL or path.
+			if !isTarballURL(res.Tarball) {
+				return "", "", false
+			}
+			return PackageKey(name, res.Tarball), res.Tarball, true
+		}
+		return PackageKey(name, version), res.Tarball, true
+	}
+	return PackageKey(name, version), registryTarballURL(name, version), true
+}
+
+// pnpmPackageId is a package id without the "/" of v6 and without peers.
+func pnpmPackageId(id string) string {
+	id = strings.TrimPrefix(id, "/")
+	if idx := strings.Index(id, "("); idx != -1 {
+		id = id[:idx]
+	}
+	return id
+}
+
+// parsePnpmPackageId turns "/name@1.0.0(peer@2.0.0)" or "name@1.0.0(peer@2.0.0)"
+// into its name and version.
+func parsePnpmPackageId(id string) (string, string) {
+	return splitDescriptor(pnpmPackageId(id))
+}
+
+// pnpmReferenceKey resolves the package a dependency points at. Plain
+// versions belong to name itself, as do git and tarball sources in v9; aliases
+// reference another package as "/real@1.0.0" (v6) or "real@1.0.0" (v9), and
+// v6 refers to sources such as "github.com/org/repo/<commit>" by their id.
+// Links are not packages.
+func pnpmReferenceKey(ids map[string]string, name, ref string) (string, bool) {
+	if ref == "" || strings.HasPrefix(ref, "link:") || strings.HasPrefix(ref, "file:") {
+		return "", false
+	}
+	ref = pnpmPackageId(ref)
+	if key, ok := ids[name+"@"+ref]; ok {
+		return key, true
+	}
+	key, ok := ids[ref]
+	return key, ok
+}
@@ -0,0 +1,207 @@
+package internal
+
+import "testing"
+
+const pnpmTestCommit = "0123456789abcdef0123456789abcdef01234567"
+
+var pnpmTestWorkspace = Workspace{Dir: "packages/a", Package: PackageJson{Name: "a", Version: "1.0.0"}}
+
+const pnpmLockV9 = `lockfileVersion: '9.0'
+
+settings:
+  autoInstallPeers: true
+  excludeLinksFromLockfile: false
+
+importers:
+
+  .:
+    dependencies:
+      a:
+        specifier: workspace:*
+        version: link:packages/a
+      ms:
+        specifier: ^2.1.3
+        version: 2.1.3
+
+  packages/a:
+    dependencies:
+      debug:
+        specifier: ^4.3.0
+        version: 4.3.4
+      mylib:
+        specifier: github:org/mylib
+        version: https://codeload.github.com/org/mylib/tar.gz/` + pnpmTestCommit + `
+      other:
+        specifier: git+https://gitlab.com/org/other.git
+        version: git+https://gitlab.com/org/other.git#` + pnpmTestCommit + `
+      r:
+        specifier: npm:react@^18.0.0
+        version: react@18.2.0
+      tar:
+        specifier: https://example.com/tar-1.0.0.tgz
+        version: https://example.com/tar-1.0.0.tgz
+
+packages:
+
+  debug@4.3.4:
+    resolution: {integrity: sha512-debug}
+
+  ms@2.1.2:
+    resolution: {integrity: sha512-ms2}
+
+  ms@2.1.3:
+    resolution: {integrity: sha512-ms3}
+
+  mylib@https://codeload.github.com/org/mylib/tar.gz/` + pnpmTestCommit + `:
+    resolution: {tarball: https://codeload.github.com/org/mylib/tar.gz/` + pnpmTestCommit + `}
+    version: 0.1.0
+
+  other@git+https://gitlab.com/org/other.git#` + pnpmTestCommit + `:
+    resolution: {commit: ` + pnpmTestCommit + `, repo: https://gitlab.com/org/other.git, type: git}
+    version: 2.0.0
+
+  react@18.2.0:
+    resolution: {integrity: sha512-react}
+
+  tar@https://example.com/tar-1.0.0.tgz:
+    resolution: {tarball: https://example.com/tar-1.0.0.tgz}
+    version: 1.0.0
+
+snapshots:
+
+  debug@4.3.4:
+    dependencies:
+      ms: 2.1.2
+
+  ms@2.1.2: {}
+
+  ms@2.1.3: {}
+
+  mylib@https://codeload.github.com/org/mylib/tar.gz/` + pnpmTestCommit + `:
+    dependencies:
+      ms: 2.1.3
+
+  other@git+https://gitlab.com/org/other.git#` + pnpmTestCommit + `: {}
+
+  react@18.2.0: {}
+
+  tar@https://example.com/tar-1.0.0.tgz: {}
+`
+
+const pnpmLockV6 = `lockfileVersion: '6.0'
+
+importers:
+
+  .:
+    dependencies:
+      a:
+        specifier: workspace:*
+        version: link:packages/a
+      ms:
+        specifier: ^2.1.3
+        version: 2.1.3
+
+  packages/a:
+    dependencies:
+      debug:
+        specifier: ^4.3.0
+        version: 4.3.4
+      mylib:
+        specifier: github:org/mylib
+        version: github.com/org/mylib/` + pnpmTestCommit + `
+      r:
+        specifier: npm:react@^18.0.0
+        version: /react@18.2.0
+
+packages:
+
+  /debug@4.3.4:
+    resolution: {integrity: sha512-debug}
+    dependencies:
+      ms: 2.1.2
+    dev: false
+
+  /ms@2.1.2:
+    resolution: {integrity: sha512-ms2}
+    dev: false
+
+  /ms@2.1.3:
+    resolution: {integrity: sha512-ms3}
+    dev: false
+
+  github.com/org/mylib/` + pnpmTestCommit + `:
+    resolution: {tarball: https://codeload.github.com/org/mylib/tar.gz/` + pnpmTestCommit + `}
+    name: mylib
+    version: 0.1.0
+    dependencies:
+      ms: 2.1.3
+    dev: false
+
+  /react@18.2.0:
+    resolution: {integrity: sha512-react}
+    dev: false
+`
+
+func TestParsePnpmLockfile(t *testing.T) {
+	useNpmrc(t, "")
+	mylib := "git+https://github.com/org/mylib.git#" + pnpmTestCommit
+	other := "git+https://gitlab.com/org/other.git#" + pnpmTestCommit
+	tar := "https://example.com/tar-1.0.0.tgz"
+	tests := []struct {
+		name    string
+		data    string
+		wsEdges map[string]string
+	}{
+		{"v6", pnpmLockV6, map[string]string{
+			"debug": "debug@4.3.4",
+			"mylib": PackageKey("mylib", mylib),
+			"r":     "react@18.2.0",
+		}},
+		{"v9", pnpmLockV9, map[string]string{
+			"debug": "debug@4.3.4",
+			"mylib": PackageKey("mylib", mylib),
+			"other": PackageKey("other", other),
+			"r":     "react@18.2.0",
+			"tar":   PackageKey("tar", tar),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolved, err := parsePnpmLockfile([]byte(tt.data), PackageJson{}, []Workspace{pnpmTestWorkspace})
+			if err != nil {
+				t.Fatal(err)
+			}
+			wsKey := pnpmTestWorkspace.key()
+			for name, key := range map[string]string{"a": wsKey, "ms": "ms@2.1.3"} {
+				if got := resolved.Dependencies[name]; got != key {
+					t.Errorf("root edge to %s = %q, want %q", name, got, key)
+				}
+			}
+			ws, ok := resolved.Packages[wsKey]
+			if !ok {
+				t.Fatalf("workspace %s is not in the graph", wsKey)
+			}
+			if ws.Tarball != "link:packages/a" || ws.Version != "1.0.0" {
+				t.Errorf("workspace node = %+v", ws)
+			}
+			if len(ws.Dependencies) != len(tt.wsEdges) {
+				t.Errorf("workspace edges = %v, want %v", ws.Dependencies, tt.wsEdges)
+			}
+			for name, key := range tt.wsEdges {
+				if got := ws.Dependencies[name]; got != key {
+					t.Errorf("workspace edge to %s = %q, want %q", name, got, key)
+				}
+			}
+			if got := resolved.Packages["debug@4.3.4"].Dependencies["ms"]; got != "ms@2.1.2" {
+				t.Errorf("debug edge to ms = %q, want ms@2.1.2", got)
+			}
+			git := resolved.Packages[PackageKey("mylib", mylib)]
+			if git.Tarball != mylib || git.Dependencies["ms"] != "ms@2.1.3" {
+				t.Errorf("mylib = %+v, want tarball %q and an edge to ms@2.1.3", git, mylib)
+			}
+			if got, want := resolved.Packages["ms@2.1.3"].Tarball, registryTarballURL("ms", "2.1.3"); got != want {
+				t.Errorf("ms@2.1.3 tarball = %q, want %q", got, want)
+			}
+		})
+	}
+}
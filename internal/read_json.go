@@ -6,12 +6,16 @@ import (
 	"path/filepath"
 )
 type PackageJson struct {
-	Name            string            `json:"name"`
-	Version         string            `json:"version"`
-	Dependencies    map[string]string `json:"dependencies"`
-	DevDependencies map[string]string `json:"devDependencies"`
-	Scripts         map[string]string `json:"scripts"`
-	Bin             interface{}       `json:"bin"`
+	Name                 string                 `json:"name"`
+	Version              string                 `json:"version"`
+	Dependencies         map[string]string      `json:"dependencies"`
+	DevDependencies      map[string]string      `json:"devDependencies"`
+	OptionalDependencies map[string]string      `json:"optionalDependencies"`
+	Scripts              map[string]string      `json:"scripts"`
+	Bin                  interface{}            `json:"bin"`
+	Overrides            map[string]interface{} `json:"overrides"`
+	Resolutions          map[string]string      `json:"resolutions"`
+	Workspaces           interface{}            `json:"workspaces"`
 }
 func ReadJson(wd string) (PackageJson, error) {
 	path := filepath.Join(wd, "package.json")
@@ -25,4 +29,9 @@ func ReadJson(wd string) (PackageJson, error) {
 		log.Fatal(err)
 	}
 	return jsn, nil
-}
\ No newline at end of file
+}
+// WritePackageJson saves the dependency sections of pkgs into the
+// package.json in wd, keeping every other field as it is.
+func WritePackageJson(wd string, pkgs PackageJson) error {
+	return writePackageJSON(filepath.Join(wd, "package.json"), pkgs)
+}
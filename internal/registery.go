@@ -6,137 +6,202 @@ import (
 	"net/http"
 	"strings"
 	"sync"
-	"time"
+
+	"github.com/chann44/tidy/internal/semver"
 )
 const REGISTRY_URL = "https://registry.npmjs.org"
 var (
 	httpClient     *http.Client
+	httpClientErr  error
 	httpClientOnce sync.Once
 	manifestCache  = make(map[string]Manifest)
+	packumentCache = make(map[string]*Packument)
+	packumentCalls = make(map[string]*packumentCall)
 	cacheMu        sync.RWMutex
 )
+// packumentCall lets concurrent resolver edges for the same package share a
+// single registry request.
+type packumentCall struct {
+	wg        sync.WaitGroup
+	packument *Packument
+	err       error
+}
 type Manifest struct {
 	Name    string `json:"name"`
 	Version string `json:"version"`
 	Dist    struct {
-		Tarball string `json:"tarball"`
-		Shasum  string `json:"shasum"`
-		Size    int    `json:"size"`
+		Tarball   string `json:"tarball"`
+		Shasum    string `json:"shasum"`
+		Integrity string `json:"integrity"`
+		Size      int    `json:"size"`
 	} `json:"dist"`
-	Dependencies map[string]string `json:"dependencies,omitempty"`
-	ID           string            `json:"_id"`
+	Dependencies         map[string]string   `json:"dependencies,omitempty"`
+	OptionalDependencies map[string]string   `json:"optionalDependencies,omitempty"`
+	PeerDependencies     map[string]string   `json:"peerDependencies,omitempty"`
+	PeerDependenciesMeta map[string]PeerMeta `json:"peerDependenciesMeta,omitempty"`
+	Os                   platformList        `json:"os,omitempty"`
+	Cpu                  platformList        `json:"cpu,omitempty"`
+	Libc                 platformList        `json:"libc,omitempty"`
+	ID                   string              `json:"_id"`
 }
-func stripVersionPrefix(version string) string {
-	version = strings.TrimSpace(version)
-	if version == "" || version == "*" || version == "x" || version == "X" || version == "latest" {
-		return "latest"
-	}
-	if strings.HasPrefix(version, "npm:") {
-		parts := strings.Split(version, "@")
-		if len(parts) >= 2 {
-			version = parts[len(parts)-1]
-		}
-	}
-	if strings.Contains(version, "||") {
-		parts := strings.Split(version, "||")
-		version = strings.TrimSpace(parts[0])
-	}
-	if strings.Contains(version, " - ") {
-		parts := strings.Split(version, " - ")
-		version = strings.TrimSpace(parts[0])
-	}
-	prefixes := []string{"^", "~", ">=", "<=", ">", "<", "=", "v"}
-	for _, prefix := range prefixes {
-		version = strings.TrimPrefix(version, prefix)
-	}
-	version = strings.TrimSpace(version)
-	if version == "" || version == "*" || version == "x" || version == "X" {
-		return "latest"
-	}
-	parts := strings.Split(version, ".")
-	if len(parts) == 1 && isDigit(parts[0]) {
-		return parts[0] + ".x"
-	}
-	if len(parts) == 2 && isDigit(parts[0]) && (parts[1] == "x" || parts[1] == "X" || parts[1] == "*") {
-		return parts[0] + ".x"
-	}
-	if strings.Contains(version, "x") || strings.Contains(version, "X") || strings.Contains(version, "*") {
-		version = strings.ReplaceAll(version, "x", "0")
-		version = strings.ReplaceAll(version, "X", "0")
-		version = strings.ReplaceAll(version, "*", "0")
-	}
-	return version
+type PeerMeta struct {
+	Optional bool `json:"optional,omitempty"`
 }
-func isDigit(s string) bool {
-	for _, c := range s {
-		if c < '0' || c > '9' {
-			return false
-		}
-	}
-	return len(s) > 0
+type Packument struct {
+	Name     string              `json:"name"`
+	DistTags map[string]string   `json:"dist-tags"`
+	Versions map[string]Manifest `json:"versions"`
 }
-func getHTTPClient() *http.Client {
+func getHTTPClient() (*http.Client, error) {
 	httpClientOnce.Do(func() {
-		httpClient = &http.Client{
-			Timeout: 60 * time.Second,  
-			Transport: &http.Transport{
-				MaxIdleConns:        100,  
-				MaxIdleConnsPerHost: 20,   
-				IdleConnTimeout:     90 * time.Second,
-				DisableCompression:  false,  
-				ForceAttemptHTTP2:   true,   
-				MaxConnsPerHost:     0,      
-				TLSHandshakeTimeout:   10 * time.Second,
-				ResponseHeaderTimeout: 10 * time.Second,
-				ExpectContinueTimeout: 1 * time.Second,
-			},
-		}
+		httpClient, httpClientErr = newRegistryClient(config())
 	})
-	return httpClient
+	return httpClient, httpClientErr
 }
 func FetchManifest(pkg, version string) (Manifest, error) {
-	exactVersion := stripVersionPrefix(version)
-	cacheKey := pkg + "@" + exactVersion
-	if diskCached, ok := LoadFromDiskCache(pkg, exactVersion); ok {
-		cacheMu.Lock()
-		manifestCache[cacheKey] = *diskCached
-		cacheMu.Unlock()
-		return *diskCached, nil
-	}
+	spec := strings.TrimSpace(version)
+	cacheKey := pkg + "@" + spec
 	cacheMu.RLock()
 	if cached, ok := manifestCache[cacheKey]; ok {
 		cacheMu.RUnlock()
 		return cached, nil
 	}
 	cacheMu.RUnlock()
-	url := REGISTRY_URL + "/" + pkg + "/" + exactVersion
-	client := getHTTPClient()
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return Manifest{}, err
-	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("User-Agent", "tidy/1.0")
-	resp, err := client.Do(req)
+	packument, err := FetchPackument(pkg)
 	if err != nil {
 		return Manifest{}, err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return Manifest{}, fmt.Errorf("failed to fetch: status %d, body: %s", resp.StatusCode, string(body))
+	manifest, err := pickManifest(packument, spec)
+	if err != nil && preferOffline() {
+		// The cached packument may predate the version asked for.
+		if packument, err = revalidatePackument(pkg); err == nil {
+			manifest, err = pickManifest(packument, spec)
+		}
 	}
-	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return Manifest{}, err
 	}
-	var manifest Manifest
-	if err := json.Unmarshal(body, &manifest); err != nil {
-		return Manifest{}, fmt.Errorf("failed to unmarshal: %w, response: %s", err, string(body[:min(len(body), 1000)]))
-	}
 	cacheMu.Lock()
 	manifestCache[cacheKey] = manifest
 	cacheMu.Unlock()
-	go SaveToDiskCache(pkg, exactVersion, manifest)
 	return manifest, nil
-}
\ No newline at end of file
+}
+func FetchPackument(pkg string) (*Packument, error) {
+	cacheMu.Lock()
+	if cached, ok := packumentCache[pkg]; ok {
+		cacheMu.Unlock()
+		return cached, nil
+	}
+	if call, ok := packumentCalls[pkg]; ok {
+		cacheMu.Unlock()
+		call.wg.Wait()
+		return call.packument, call.err
+	}
+	call := &packumentCall{}
+	call.wg.Add(1)
+	packumentCalls[pkg] = call
+	cacheMu.Unlock()
+	call.packument, call.err = fetchPackument(pkg, false)
+	cacheMu.Lock()
+	if call.err == nil {
+		packumentCache[pkg] = call.packument
+	}
+	delete(packumentCalls, pkg)
+	cacheMu.Unlock()
+	call.wg.Done()
+	return call.packument, call.err
+}
+// revalidatePackument asks the registry again for a packument that was taken
+// from the cache without checking it.
+func revalidatePackument(pkg string) (*Packument, error) {
+	packument, err := fetchPackument(pkg, true)
+	if err != nil {
+		return nil, err
+	}
+	cacheMu.Lock()
+	packumentCache[pkg] = packument
+	cacheMu.Unlock()
+	return packument, nil
+}
+// fetchPackument reads a packument from the disk cache while it is fresh, or
+// at any age when offline or preferring offline, and otherwise from the
+// registry. revalidate always asks the registry. Installs from a mirror only
+// see the packuments in it.
+func fetchPackument(pkg string, revalidate bool) (*Packument, error) {
+	if dir := mirrorDir(); dir != "" {
+		return readMirrorPackument(dir, pkg)
+	}
+	url := registryFor(pkg) + "/" + escapePackageName(pkg)
+	cached, ok := LoadFromDiskCache(url)
+	if ok && !revalidate && (cached.Fresh() || Offline() || preferOffline()) {
+		return &cached.Packument, nil
+	}
+	if !ok && Offline() {
+		return nil, fmt.Errorf("%s is not in the packument cache", pkg)
+	}
+	header := http.Header{"Accept": {packumentAccept}}
+	if ok && cached.ETag != "" {
+		header.Set("If-None-Match", cached.ETag)
+	}
+	if ok && cached.LastModified != "" {
+		header.Set("If-Modified-Since", cached.LastModified)
+	}
+	var fetched CachedPackument
+	err := registryGet(url, header, func(resp *http.Response) error {
+		if resp.StatusCode == http.StatusNotModified && ok {
+			fetched = *cached
+			fetched.revalidated(resp.Header)
+			return nil
+		}
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			return fmt.Errorf("failed to fetch %s: status %d, body: %s", pkg, resp.StatusCode, strings.TrimSpace(string(body)))
+		}
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		fetched = CachedPackument{}
+		if err := json.Unmarshal(body, &fetched.Packument); err != nil {
+			return fmt.Errorf("failed to unmarshal: %w, response: %s", err, string(body[:min(len(body), 1000)]))
+		}
+		fetched.revalidated(resp.Header)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	SaveToDiskCache(url, &fetched)
+	return &fetched.Packument, nil
+}
+func escapePackageName(pkg string) string {
+	if strings.HasPrefix(pkg, "@") {
+		return strings.Replace(pkg, "/", "%2f", 1)
+	}
+	return pkg
+}
+// pickManifest returns the version a dist-tag points at, or otherwise the
+// highest published version that satisfies the range.
+func pickManifest(packument *Packument, spec string) (Manifest, error) {
+	if spec == "" {
+		spec = "*"
+	}
+	if tagged, ok := packument.DistTags[spec]; ok {
+		if manifest, ok := packument.Versions[tagged]; ok {
+			return manifest, nil
+		}
+	}
+	rng, err := semver.ParseRange(spec)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("%s: %q is neither a dist-tag nor a valid range", packument.Name, spec)
+	}
+	versions := make([]string, 0, len(packument.Versions))
+	for v := range packument.Versions {
+		versions = append(versions, v)
+	}
+	best := semver.MaxSatisfying(versions, rng)
+	if best == "" {
+		return Manifest{}, fmt.Errorf("no version of %s satisfies %q", packument.Name, spec)
+	}
+	return packument.Versions[best], nil
+}
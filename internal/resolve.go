@@ -1,216 +1,567 @@
 package internal
 import (
-	"context"
-	"crypto/sha256"
-	"encoding/hex"
-	"encoding/json"
+	"fmt"
 	"os"
-	"path/filepath"
+	"strings"
 	"sync"
-	"time"
+
+	"github.com/chann44/tidy/internal/semver"
 )
 type pkg struct {
 	name    string
 	vesrion string
+	parent  string
+	path    []string
+	// optional is set for optional dependencies and everything below them,
+	// which may fail to resolve without failing the resolution.
+	optional bool
 }
-type Queue []pkg
+// Deps is one package of the graph. Its peer dependencies are kept so that
+// they can be checked against whatever ends up providing them, and its
+// platform constraints so that an install can skip it. Peers maps each peer
+// to the package providing it; unlike Dependencies those are not installed
+// for it, only looked up. Optional is set when the package is only needed
+// through optional dependencies.
 type Deps struct {
-	Version      string
-	Tarball      string
-	Dependencies map[string]Deps
+	Name                 string              `json:"name"`
+	Version              string              `json:"version"`
+	Tarball              string              `json:"resolved"`
+	Integrity            string              `json:"integrity,omitempty"`
+	Dependencies         map[string]string   `json:"dependencies,omitempty"`
+	OptionalDependencies map[string]string   `json:"optionalDependencies,omitempty"`
+	PeerDependencies     map[string]string   `json:"peerDependencies,omitempty"`
+	PeerDependenciesMeta map[string]PeerMeta `json:"peerDependenciesMeta,omitempty"`
+	Peers                map[string]string   `json:"peers,omitempty"`
+	Os                   []string            `json:"os,omitempty"`
+	Cpu                  []string            `json:"cpu,omitempty"`
+	Libc                 []string            `json:"libc,omitempty"`
+	Optional             bool                `json:"optional,omitempty"`
 }
-type Resolved map[string]Deps
-type ResolutionCache struct {
-	PackageHash string   `json:"package_hash"`
-	Resolved    Resolved `json:"json"`
+// Resolved is the dependency graph. Packages are keyed by name@version and
+// every edge (root or package dependency) points at one of those keys, so
+// different dependents can hold different versions of the same name.
+type Resolved struct {
+	Dependencies map[string]string `json:"dependencies"`
+	Packages     map[string]Deps   `json:"packages"`
 }
-func getResolutionCachePath(root string) string {
-	return filepath.Join(root, "node_modules", ".tidy-resolution-cache.json")
+func PackageKey(name, version string) string {
+	return name + "@" + version
 }
-func calculatePackageHash(pkgs PackageJson) string {
-	data, _ := json.Marshal(pkgs)
-	hash := sha256.Sum256(data)
-	return hex.EncodeToString(hash[:])
+func newResolved() Resolved {
+	return Resolved{
+		Dependencies: make(map[string]string),
+		Packages:     make(map[string]Deps),
+	}
 }
-func Resolve(pkgs PackageJson) (Resolved, error) {
-	root, _ := os.Getwd()
-	cachePath := getResolutionCachePath(root)
-	currentHash := calculatePackageHash(pkgs)
-	if data, err := os.ReadFile(cachePath); err == nil {
-		var cache ResolutionCache
-		if err := json.Unmarshal(data, &cache); err == nil {
-			if cache.PackageHash == currentHash {
-				return cache.Resolved, nil
-			}
+func (r Resolved) link(parent, name, key string) {
+	if parent == "" {
+		r.Dependencies[name] = key
+		return
+	}
+	r.Packages[parent].Dependencies[name] = key
+}
+// ResolveError names a dependency that could not be resolved together with
+// the chain of packages that required it.
+type ResolveError struct {
+	Name  string
+	Range string
+	Path  []string
+	Err   error
+}
+func (e *ResolveError) Error() string {
+	chain := append([]string{"package.json"}, e.Path...)
+	return fmt.Sprintf("%s@%s (via %s): %v", e.Name, e.Range, strings.Join(chain, " > "), e.Err)
+}
+func (e *ResolveError) Unwrap() error {
+	return e.Err
+}
+type ResolveErrors []*ResolveError
+func (e ResolveErrors) Error() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("failed to resolve %d package(s):", len(e)))
+	for _, err := range e {
+		sb.WriteString("\n  - " + err.Error())
+	}
+	return sb.String()
+}
+type resolver struct {
+	mu        sync.Mutex
+	wg        sync.WaitGroup
+	semaphore chan struct{}
+	resolved  Resolved
+	failures  map[string]*ResolveError
+	locked    Resolved
+	lockedBy  map[string][]string
+	// lockedSpecs are the package.json specs the locked graph was resolved
+	// for, by directory, needed to tell whether a locked git dependency is
+	// still wanted.
+	lockedSpecs   map[string]map[string]string
+	overrides     []overrideRule
+	workspaces    map[string]Workspace
+	workspaceDirs map[string]string
+	rootOptional  map[string]string
+}
+func newResolver() *resolver {
+	const maxConcurrency = 100
+	return &resolver{
+		semaphore:     make(chan struct{}, maxConcurrency),
+		resolved:      newResolved(),
+		failures:      make(map[string]*ResolveError),
+		locked:        newResolved(),
+		lockedBy:      make(map[string][]string),
+		workspaces:    make(map[string]Workspace),
+		workspaceDirs: make(map[string]string),
+	}
+}
+// preferLocked makes the resolver reuse versions from a previous resolution
+// wherever they still satisfy the requested range.
+func (r *resolver) preferLocked(locked Resolved, specs map[string]map[string]string) {
+	r.locked = locked
+	r.lockedSpecs = specs
+	for _, key := range sortedKeys(locked.Packages) {
+		deps := locked.Packages[key]
+		if key != PackageKey(deps.Name, deps.Version) {
+			// Only registry packages can stand in for a range.
+			continue
 		}
+		r.lockedBy[deps.Name] = append(r.lockedBy[deps.Name], key)
 	}
-	resolved := make(Resolved)
-	var resolvedMu sync.RWMutex
-	queue := make(chan pkg, 1000)
-	var pendingMu sync.Mutex
-	pendingCount := 0
-	activeWorkers := 0
-	const maxConcurrency = 100  
-	semaphore := make(chan struct{}, maxConcurrency)
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	var wg sync.WaitGroup
-	processing := make(map[string]bool)
-	var processingMu sync.Mutex
-	worker := func() {
-		defer wg.Done()
-		for current := range queue {
-			pendingMu.Lock()
-			activeWorkers++
-			pendingMu.Unlock()
-			resolvedMu.RLock()
-			alreadyResolved := false
-			if _, exists := resolved[current.name]; exists {
-				alreadyResolved = true
-			}
-			resolvedMu.RUnlock()
-			if alreadyResolved {
-				pendingMu.Lock()
-				pendingCount--
-				activeWorkers--
-				pendingMu.Unlock()
-				continue
-			}
-			processingMu.Lock()
-			if processing[current.name] {
-				processingMu.Unlock()
-				pendingMu.Lock()
-				pendingCount--
-				activeWorkers--
-				pendingMu.Unlock()
-				continue
-			}
-			processing[current.name] = true
-			processingMu.Unlock()
-			pendingMu.Lock()
-			pendingCount--
-			pendingMu.Unlock()
-			semaphore <- struct{}{}
-			manifest, err := FetchManifest(current.name, current.vesrion)
-			<-semaphore
-			processingMu.Lock()
-			delete(processing, current.name)
-			processingMu.Unlock()
-			pendingMu.Lock()
-			activeWorkers--
-			pendingMu.Unlock()
-			if err != nil {
-				continue
-			}
-			resolvedMu.Lock()
-			if _, exists := resolved[current.name]; exists {
-				resolvedMu.Unlock()
+}
+// lockedKey picks the locked package for an edge: the one the lock itself
+// used for the same parent if it still fits, otherwise the highest locked
+// version of name satisfying the range. edgeName differs from name for
+// aliases.
+func (r *resolver) lockedKey(parent, edgeName, name, spec string) (string, bool) {
+	edges := r.locked.Dependencies
+	if parent != "" {
+		edges = r.locked.Packages[parent].Dependencies
+	}
+	if isPinnedSpec(spec) {
+		// A git ref or URL can move, so only what the lock recorded for this
+		// very edge is reused, and only while the project asks for the same one.
+		key, ok := edges[edgeName]
+		if !ok {
+			return "", false
+		}
+		if dir, declared := r.declaringDir(parent); declared && r.lockedSpecs[dir][edgeName] != spec {
+			return "", false
+		}
+		return key, isPinnedSpec(r.locked.Packages[key].Tarball)
+	}
+	rng, err := semver.ParseRange(spec)
+	if err != nil {
+		return "", false
+	}
+	if key, ok := edges[edgeName]; ok && r.locked.Packages[key].Name == name {
+		if v, err := semver.Parse(r.locked.Packages[key].Version); err == nil && rng.Test(v) {
+			return key, true
+		}
+	}
+	best := ""
+	var bestVersion *semver.Version
+	for _, key := range r.lockedBy[name] {
+		v, err := semver.Parse(r.locked.Packages[key].Version)
+		if err != nil || !rng.Test(v) {
+			continue
+		}
+		if bestVersion == nil || semver.Compare(v, bestVersion) > 0 {
+			best, bestVersion = key, v
+		}
+	}
+	return best, best != ""
+}
+// declaringDir reports whether parent is the project or one of its workspaces,
+// whose edges come from a package.json, and the directory of that file.
+func (r *resolver) declaringDir(parent string) (string, bool) {
+	if parent == "" {
+		return "", true
+	}
+	dir, ok := r.workspaceDirs[parent]
+	return dir, ok
+}
+// addWorkspaces puts every workspace into the graph as a linked package
+// visible from the root node_modules and returns the edges of their own
+// dependencies.
+func (r *resolver) addWorkspaces(workspaces []Workspace) []pkg {
+	var edges []pkg
+	for _, ws := range workspaces {
+		key := ws.key()
+		r.workspaces[ws.Package.Name] = ws
+		r.workspaceDirs[key] = ws.Dir
+		r.resolved.Packages[key] = Deps{
+			Name:                 ws.Package.Name,
+			Version:              ws.Package.Version,
+			Tarball:              ws.spec(),
+			Dependencies:         make(map[string]string),
+			OptionalDependencies: ws.Package.OptionalDependencies,
+		}
+		r.resolved.link("", ws.Package.Name, key)
+		for _, edge := range transformPackageJson(ws.Package) {
+			edge.parent, edge.path = key, []string{key}
+			edges = append(edges, edge)
+		}
+	}
+	return edges
+}
+// matchWorkspace links name to the workspace of that name when spec uses the
+// workspace: protocol or is a range the workspace's version satisfies.
+func (r *resolver) matchWorkspace(name, spec string) (string, bool, error) {
+	rng, protocol := strings.CutPrefix(spec, "workspace:")
+	ws, ok := r.workspaces[name]
+	switch {
+	case !ok && protocol:
+		return "", false, fmt.Errorf("no workspace is named %s", name)
+	case !ok:
+		return "", false, nil
+	case rng == "*" || rng == "^" || rng == "~" || rng == "":
+		return ws.key(), true, nil
+	case semver.Satisfies(ws.Package.Version, rng):
+		return ws.key(), true, nil
+	case protocol:
+		return "", false, fmt.Errorf("workspace %s is at %s, which does not satisfy %s", name, ws.Package.Version, rng)
+	}
+	return "", false, nil
+}
+// override returns the spec an override or resolution forces for edge. Like
+// npm, direct dependencies of the project keep the spec from package.json.
+func (r *resolver) override(edge pkg) string {
+	if edge.parent == "" {
+		return edge.vesrion
+	}
+	name, spec := edgeTarget(edge)
+	for _, rule := range r.overrides {
+		if rule.target.name != name || !rule.matchesPath(edge.path) {
+			continue
+		}
+		if rule.target.rng != "" {
+			r.semaphore <- struct{}{}
+			manifest, _, err := fetchEdgeManifest(name, spec)
+			<-r.semaphore
+			if err != nil || !semver.Satisfies(manifest.Version, rule.target.rng) {
 				continue
 			}
-			resolved[current.name] = Deps{
-				Version:      manifest.Version,
-				Tarball:      manifest.Dist.Tarball,
-				Dependencies: make(map[string]Deps),
-			}
-			resolvedMu.Unlock()
-			for depName, depVersion := range manifest.Dependencies {
-				select {
-				case <-ctx.Done():
-					return
-				default:
-				}
-				resolvedMu.RLock()
-				if _, ok := resolved[depName]; ok {
-					resolvedMu.RUnlock()
-					continue
-				}
-				resolvedMu.RUnlock()
-				processingMu.Lock()
-				if processing[depName] {
-					processingMu.Unlock()
-					continue
-				}
-				processingMu.Unlock()
-				select {
-				case <-ctx.Done():
-					return
-				case queue <- pkg{name: depName, vesrion: depVersion}:
-					pendingMu.Lock()
-					pendingCount++
-					pendingMu.Unlock()
-				default:
-				}
-			}
 		}
+		return rule.spec
 	}
-	numWorkers := maxConcurrency
-	for i := 0; i < numWorkers; i++ {
-		wg.Add(1)
-		go worker()
+	return edge.vesrion
+}
+// reuseLocked links edge to a locked package and copies its locked subtree
+// into the new graph without going to the registry.
+func (r *resolver) reuseLocked(edge pkg, key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	pending := []string{key}
+	for len(pending) > 0 {
+		current := pending[0]
+		pending = pending[1:]
+		if _, seen := r.resolved.Packages[current]; seen {
+			continue
+		}
+		deps, ok := r.locked.Packages[current]
+		if !ok {
+			continue
+		}
+		copied := deps
+		copied.Dependencies = make(map[string]string, len(deps.Dependencies))
+		for name, depKey := range deps.Dependencies {
+			copied.Dependencies[name] = depKey
+			pending = append(pending, depKey)
+		}
+		copied.Peers = nil
+		r.resolved.Packages[current] = copied
 	}
-	for _, pkg := range transformPackageJson(pkgs) {
-		queue <- pkg
-		pendingMu.Lock()
-		pendingCount++
-		pendingMu.Unlock()
+	r.resolved.link(edge.parent, edge.name, key)
+}
+// schedule accounts for the edge before its goroutine starts, so wg.Wait in
+// run returns exactly when no edge is queued or being fetched.
+func (r *resolver) schedule(edge pkg) {
+	r.wg.Add(1)
+	go r.visit(edge)
+}
+// edgeTarget is the package and range an edge fetches. For an alias such as
+// "npm:react@^17" that is the real package, while the edge keeps its own name.
+func edgeTarget(edge pkg) (string, string) {
+	if target, rng, ok := parseAlias(edge.vesrion); ok {
+		return target, rng
 	}
-	var queueOnce sync.Once
-	closeQueue := func() {
-		queueOnce.Do(func() {
-			close(queue)
-		})
+	return edge.name, edge.vesrion
+}
+// fetchEdgeManifest reads the manifest an edge resolves to. Packages that do
+// not come from the registry also return their source, which keys them in
+// the graph since their version alone does not identify them.
+func fetchEdgeManifest(name, spec string) (Manifest, string, error) {
+	if _, ok := parseGitSpec(spec); ok {
+		manifest, err := FetchGitManifest(spec)
+		return manifest, manifest.Dist.Tarball, err
+	}
+	if f, ok := parseFileSpec(spec); ok {
+		manifest, err := readFileManifest(f)
+		return manifest, f.String(), err
+	}
+	if isTarballURL(spec) {
+		manifest, err := FetchTarballManifest(spec)
+		return manifest, spec, err
 	}
-	go func() {
-		ticker := time.NewTicker(50 * time.Millisecond)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ticker.C:
-				pendingMu.Lock()
-				pending := pendingCount
-				active := activeWorkers
-				pendingMu.Unlock()
-				if pending == 0 && active == 0 {
-					time.Sleep(100 * time.Millisecond)
-					pendingMu.Lock()
-					if pendingCount == 0 && activeWorkers == 0 {
-						pendingMu.Unlock()
-						cancel()
-						closeQueue()
-						return
-					}
-					pendingMu.Unlock()
-				}
-			case <-ctx.Done():
-				return
+	manifest, err := FetchManifest(name, spec)
+	return manifest, "", err
+}
+// rebase makes a relative file: or link: spec declared by a file: directory
+// package relative to the project root, like the specs in package.json.
+func (r *resolver) rebase(edge pkg) string {
+	f, ok := parseFileSpec(edge.vesrion)
+	if !ok || edge.parent == "" {
+		return edge.vesrion
+	}
+	r.mu.Lock()
+	parent := r.resolved.Packages[edge.parent]
+	r.mu.Unlock()
+	if dir, ok := parseFileSpec(parent.Tarball); ok && !dir.tarball {
+		return f.rebase(dir.path).String()
+	}
+	return edge.vesrion
+}
+func (r *resolver) visit(edge pkg) {
+	defer r.wg.Done()
+	edge.vesrion = r.override(edge)
+	edge.vesrion = r.rebase(edge)
+	if key, ok, err := r.matchWorkspace(edge.name, edge.vesrion); err != nil || ok {
+		if err != nil {
+			r.fail(edge, err)
+			return
+		}
+		r.mu.Lock()
+		r.resolved.link(edge.parent, edge.name, key)
+		r.mu.Unlock()
+		return
+	}
+	name, spec := edgeTarget(edge)
+	if key, ok := r.lockedKey(edge.parent, edge.name, name, spec); ok {
+		r.reuseLocked(edge, key)
+		return
+	}
+	r.semaphore <- struct{}{}
+	manifest, source, err := fetchEdgeManifest(name, spec)
+	<-r.semaphore
+	if err != nil {
+		if edge.optional {
+			skipOptional(edge, err)
+		} else {
+			r.fail(edge, err)
+		}
+		return
+	}
+	if manifest.Name != "" {
+		name = manifest.Name
+	}
+	key := PackageKey(name, manifest.Version)
+	if source != "" {
+		key = PackageKey(name, source)
+	}
+	r.mu.Lock()
+	_, seen := r.resolved.Packages[key]
+	if !seen {
+		r.resolved.Packages[key] = Deps{
+			Name:                 name,
+			Version:              manifest.Version,
+			Tarball:              manifest.Dist.Tarball,
+			Integrity:            manifest.integrity(),
+			Dependencies:         make(map[string]string),
+			OptionalDependencies: manifest.OptionalDependencies,
+			PeerDependencies:     manifest.PeerDependencies,
+			PeerDependenciesMeta: manifest.PeerDependenciesMeta,
+			Os:                   manifest.Os,
+			Cpu:                  manifest.Cpu,
+			Libc:                 manifest.Libc,
+		}
+	}
+	r.resolved.link(edge.parent, edge.name, key)
+	r.mu.Unlock()
+	if f, ok := parseFileSpec(source); seen || (ok && f.link) {
+		// Linked packages bring their own node_modules.
+		return
+	}
+	path := append(append([]string(nil), edge.path...), key)
+	for _, depName := range sortedKeys(manifest.Dependencies) {
+		if _, ok := manifest.OptionalDependencies[depName]; ok {
+			continue
+		}
+		r.schedule(pkg{name: depName, vesrion: manifest.Dependencies[depName], parent: key, path: path, optional: edge.optional})
+	}
+	for _, depName := range sortedKeys(manifest.OptionalDependencies) {
+		r.schedule(pkg{name: depName, vesrion: manifest.OptionalDependencies[depName], parent: key, path: path, optional: true})
+	}
+}
+// fail records one error per name@range, keeping the shortest path to it so
+// the report does not depend on which goroutine got there first.
+func (r *resolver) fail(edge pkg, err error) {
+	id := edge.name + "@" + edge.vesrion
+	candidate := &ResolveError{Name: edge.name, Range: edge.vesrion, Path: edge.path, Err: err}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing, ok := r.failures[id]; ok && !shorterPath(candidate.Path, existing.Path) {
+		return
+	}
+	r.failures[id] = candidate
+}
+var (
+	optionalMu  sync.Mutex
+	optionalLog []string
+)
+// skipOptional records an optional dependency left out of the graph because
+// it failed to resolve.
+func skipOptional(edge pkg, err error) {
+	msg := (&ResolveError{Name: edge.name, Range: edge.vesrion, Path: edge.path, Err: err}).Error()
+	optionalMu.Lock()
+	defer optionalMu.Unlock()
+	for _, logged := range optionalLog {
+		if logged == msg {
+			return
+		}
+	}
+	optionalLog = append(optionalLog, msg)
+}
+// OptionalWarnings describes the optional dependencies skipped so far
+// because they could not be resolved, for example after a network error.
+func OptionalWarnings() []string {
+	optionalMu.Lock()
+	defer optionalMu.Unlock()
+	return append([]string(nil), optionalLog...)
+}
+func shorterPath(a, b []string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return strings.Join(a, " ") < strings.Join(b, " ")
+}
+func (r *resolver) run(roots []pkg) (Resolved, error) {
+	for _, root := range roots {
+		r.schedule(root)
+	}
+	r.wg.Wait()
+	tried := make(map[string]bool)
+	for r.linkPeers(tried) {
+		r.wg.Wait()
+	}
+	markOptional(r.resolved, r.rootOptional)
+	if len(r.failures) == 0 {
+		return r.resolved, nil
+	}
+	errs := make(ResolveErrors, 0, len(r.failures))
+	for _, id := range sortedKeys(r.failures) {
+		errs = append(errs, r.failures[id])
+	}
+	return r.resolved, errs
+}
+// markOptional flags the packages that are only reachable through optional
+// dependencies, so an install can skip them or survive their failure.
+func markOptional(res Resolved, rootOptional map[string]string) {
+	required := make(map[string]bool)
+	var pending []string
+	for name, key := range res.Dependencies {
+		if _, ok := rootOptional[name]; !ok {
+			pending = append(pending, key)
+		}
+	}
+	for len(pending) > 0 {
+		key := pending[0]
+		pending = pending[1:]
+		if required[key] {
+			continue
+		}
+		required[key] = true
+		deps := res.Packages[key]
+		for name, depKey := range deps.Dependencies {
+			if _, ok := deps.OptionalDependencies[name]; !ok {
+				pending = append(pending, depKey)
 			}
 		}
-	}()
-	wg.Wait()
-	closeQueue()
-	_ = os.MkdirAll(filepath.Dir(cachePath), 0755)
-	cache := ResolutionCache{
-		PackageHash: currentHash,
-		Resolved:    resolved,
 	}
-	if cacheData, err := json.Marshal(cache); err == nil {
-		_ = os.WriteFile(cachePath, cacheData, 0644)
+	for key, deps := range res.Packages {
+		deps.Optional = !required[key]
+		res.Packages[key] = deps
+	}
+}
+// Resolve returns the graph recorded in tidy.lock when it is up to date with
+// pkgs. Otherwise it resolves against the registry, keeping locked versions
+// that still satisfy their ranges, and rewrites the lock. Without a tidy.lock
+// the lockfile of another package manager seeds the locked versions instead.
+func Resolve(pkgs PackageJson) (Resolved, error) {
+	root, _ := os.Getwd()
+	workspaces, err := FindWorkspaces(root, pkgs)
+	if err != nil {
+		return Resolved{}, err
+	}
+	lock, err := ReadLockfile(root)
+	if err == nil {
+		if len(lock.Diff(pkgs, workspaces)) == 0 {
+			return lock.Resolved(), nil
+		}
+		return resolveLocked(root, pkgs, workspaces, lock.Resolved(), lock.declaredSpecifiers())
+	}
+	if !os.IsNotExist(err) {
+		return Resolved{}, err
+	}
+	imported, source, err := ImportLockfile(root, pkgs, workspaces)
+	if err == nil {
+		return resolveLocked(root, pkgs, workspaces, imported, nil)
+	}
+	if source != "" {
+		warnImport(fmt.Sprintf("could not import %s, resolving every package from the registry: %v", source, err))
+	}
+	return resolveLocked(root, pkgs, workspaces, newResolved(), nil)
+}
+// Import seeds the resolution from another package manager's lockfile even
+// when a tidy.lock already exists, and writes the result to tidy.lock.
+func Import(root string, pkgs PackageJson) (Resolved, string, error) {
+	workspaces, err := FindWorkspaces(root, pkgs)
+	if err != nil {
+		return Resolved{}, "", err
+	}
+	imported, source, err := ImportLockfile(root, pkgs, workspaces)
+	if err != nil {
+		return Resolved{}, source, err
+	}
+	resolved, err := resolveLocked(root, pkgs, workspaces, imported, nil)
+	return resolved, source, err
+}
+func resolveLocked(root string, pkgs PackageJson, workspaces []Workspace, locked Resolved, lockedSpecs map[string]map[string]string) (Resolved, error) {
+	r := newResolver()
+	r.preferLocked(locked, lockedSpecs)
+	r.overrides = parseOverrides(pkgs)
+	r.rootOptional = pkgs.OptionalDependencies
+	edges := r.addWorkspaces(workspaces)
+	resolved, err := r.run(append(transformPackageJson(pkgs), edges...))
+	if err != nil {
+		return resolved, err
+	}
+	if err := WriteLockfile(root, NewLockfile(pkgs, workspaces, resolved)); err != nil {
+		return resolved, err
 	}
 	return resolved, nil
 }
 func transformPackageJson(pkgs PackageJson) []pkg {
 	var pkgsList []pkg
-	for name, version := range pkgs.Dependencies {
+	for _, name := range sortedKeys(pkgs.Dependencies) {
+		if _, ok := pkgs.OptionalDependencies[name]; ok {
+			continue
+		}
 		pkgsList = append(pkgsList, pkg{
 			name:    name,
-			vesrion: version,
+			vesrion: pkgs.Dependencies[name],
 		})
 	}
-	for name, version := range pkgs.DevDependencies {
+	for _, name := range sortedKeys(pkgs.DevDependencies) {
 		pkgsList = append(pkgsList, pkg{
 			name:    name,
-			vesrion: version,
+			vesrion: pkgs.DevDependencies[name],
+		})
+	}
+	for _, name := range sortedKeys(pkgs.OptionalDependencies) {
+		pkgsList = append(pkgsList, pkg{
+			name:     name,
+			vesrion:  pkgs.OptionalDependencies[name],
+			optional: true,
 		})
 	}
 	return pkgsList
-}
\ No newline at end of file
+}
@@ -0,0 +1,165 @@
+package internal
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// retryPolicy is npm's fetch-retries, fetch-retry-factor,
+// fetch-retry-mintimeout, fetch-retry-maxtimeout and fetch-timeout, with the
+// same defaults.
+type retryPolicy struct {
+	retries    int
+	factor     float64
+	minTimeout time.Duration
+	maxTimeout time.Duration
+	timeout    time.Duration
+}
+
+func newRetryPolicy(cfg npmConfig) retryPolicy {
+	number := func(key string, def float64) float64 {
+		if value, err := strconv.ParseFloat(cfg[key], 64); err == nil && value >= 0 {
+			return value
+		}
+		return def
+	}
+	return retryPolicy{
+		retries:    int(number("fetch-retries", 2)),
+		factor:     number("fetch-retry-factor", 10),
+		minTimeout: time.Duration(number("fetch-retry-mintimeout", 10000)) * time.Millisecond,
+		maxTimeout: time.Duration(number("fetch-retry-maxtimeout", 60000)) * time.Millisecond,
+		timeout:    time.Duration(number("fetch-timeout", 300000)) * time.Millisecond,
+	}
+}
+
+// backoff is the wait before retry number attempt+1: exponential, capped at
+// maxTimeout, and jittered over its upper half so that parallel requests
+// failing together do not retry together.
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	delay := float64(p.minTimeout) * math.Pow(p.factor, float64(attempt))
+	delay = math.Min(delay, float64(p.maxTimeout))
+	return time.Duration(delay/2 + rand.Float64()*delay/2)
+}
+
+var (
+	retryMu  sync.Mutex
+	retryLog []string
+)
+
+// Retries lists the requests retried so far, one line per retry.
+func Retries() []string {
+	retryMu.Lock()
+	defer retryMu.Unlock()
+	return append([]string(nil), retryLog...)
+}
+
+// registryGet sends a GET for url, retrying connection failures, timeouts
+// and 408, 429 and 5xx responses. Every other response is handed to read,
+// which is retried as well when the body breaks off mid-transfer. A 429 or
+// 503 with Retry-After waits as long as the server asks, up to the policy's
+// maximum.
+func registryGet(url string, header http.Header, read func(*http.Response) error) error {
+	if Offline() {
+		return fmt.Errorf("%s is not cached and the network is off (--offline)", url)
+	}
+	client, err := getHTTPClient()
+	if err != nil {
+		return err
+	}
+	policy := newRetryPolicy(config())
+	for attempt := 0; ; attempt++ {
+		wait, err := registryAttempt(client, policy, url, header, read, attempt == policy.retries)
+		if err == nil || wait < 0 {
+			return err
+		}
+		if wait == 0 {
+			wait = policy.backoff(attempt)
+		}
+		retryMu.Lock()
+		retryLog = append(retryLog, fmt.Sprintf("GET %s: %v (retry %d of %d in %s)", url, err, attempt+1, policy.retries, wait.Round(time.Millisecond)))
+		retryMu.Unlock()
+		time.Sleep(wait)
+	}
+}
+
+// registryAttempt makes one request. A negative wait means err is final;
+// otherwise the request may be retried after wait, or after the policy's
+// backoff when wait is zero.
+func registryAttempt(client *http.Client, policy retryPolicy, url string, header http.Header, read func(*http.Response) error, last bool) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), policy.timeout)
+	defer cancel()
+	req, err := registryRequest(url)
+	if err != nil {
+		return -1, err
+	}
+	req = req.WithContext(ctx)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		if last || !transient(err) {
+			return -1, err
+		}
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if retryableStatus(resp.StatusCode) && !last {
+		return min(retryAfter(resp), policy.maxTimeout), fmt.Errorf("%s", resp.Status)
+	}
+	if err := read(resp); err != nil {
+		if last || !transient(err) {
+			return -1, err
+		}
+		return 0, err
+	}
+	return 0, nil
+}
+
+func retryableStatus(status int) bool {
+	return status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= 500
+}
+
+// retryAfter reads the Retry-After header, in seconds or as a date, and
+// returns zero when there is none.
+func retryAfter(resp *http.Response) time.Duration {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
+
+// transient reports whether err is a network failure worth retrying, as
+// opposed to a certificate problem or an error in what was received.
+func transient(err error) bool {
+	var verifyErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	if errors.As(err, &verifyErr) || errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+)
+
+func TestRegistryGetRetries(t *testing.T) {
+	useNpmrc(t, "fetch-retries=2\nfetch-retry-mintimeout=1\nfetch-retry-maxtimeout=5\n")
+	// reset drops the connection without a response, which the client sees
+	// as a reset or an unexpected EOF.
+	reset := func(w http.ResponseWriter) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if tcp, ok := conn.(*net.TCPConn); ok {
+			tcp.SetLinger(0)
+		}
+		conn.Close()
+	}
+	tests := []struct {
+		name string
+		fail func(w http.ResponseWriter)
+		// failures is how many requests fail before one succeeds; negative
+		// means they all do.
+		failures int
+		requests int32
+		wantErr  bool
+	}{
+		{"503 then ok", func(w http.ResponseWriter) { http.Error(w, "busy", http.StatusServiceUnavailable) }, 2, 3, false},
+		{"429 with Retry-After", func(w http.ResponseWriter) {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+		}, 1, 2, false},
+		{"connection reset then ok", reset, 1, 2, false},
+		{"404 is final", func(w http.ResponseWriter) { http.NotFound(w, nil) }, -1, 1, true},
+		{"503 until the budget runs out", func(w http.ResponseWriter) { http.Error(w, "busy", http.StatusServiceUnavailable) }, -1, 3, true},
+		{"resets until the budget runs out", reset, -1, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := requests.Add(1)
+				if tt.failures < 0 || int(n) <= tt.failures {
+					tt.fail(w)
+					return
+				}
+				w.Write([]byte("ok"))
+			}))
+			defer srv.Close()
+			before := len(Retries())
+			err := registryGet(srv.URL+"/pkg", nil, func(resp *http.Response) error {
+				if resp.StatusCode != http.StatusOK {
+					return fmt.Errorf("%s", resp.Status)
+				}
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("registryGet error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := requests.Load(); got != tt.requests {
+				t.Errorf("%d requests, want %d", got, tt.requests)
+			}
+			if got := len(Retries()) - before; got != int(tt.requests)-1 {
+				t.Errorf("%d retries logged, want %d", got, tt.requests-1)
+			}
+		})
+	}
+}
+
+func TestTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, true},
+		{fmt.Errorf("reading body: %w", syscall.ECONNRESET), true},
+		{io.ErrUnexpectedEOF, true},
+		{x509.UnknownAuthorityError{}, false},
+		{errors.New("invalid JSON"), false},
+	}
+	for _, tt := range tests {
+		if got := transient(tt.err); got != tt.want {
+			t.Errorf("transient(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
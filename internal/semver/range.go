@@ -0,0 +1,369 @@
+package semver
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type comparator struct {
+	op string
+	v  *Version
+}
+
+// Range is a parsed npm version range: a union of comparator sets, each of
+// which must fully match for a version to satisfy the range.
+type Range struct {
+	raw string
+	set [][]comparator
+}
+
+var operatorSpace = regexp.MustCompile(`(~>|~|\^|>=|<=|>|<|=)\s+`)
+
+func ParseRange(s string) (*Range, error) {
+	r := &Range{raw: s}
+	for _, alt := range strings.Split(s, "||") {
+		set, err := parseComparatorSet(alt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid range %q: %w", s, err)
+		}
+		r.set = append(r.set, set)
+	}
+	return r, nil
+}
+
+func MustParseRange(s string) *Range {
+	r, err := ParseRange(s)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func ValidRange(s string) bool {
+	_, err := ParseRange(s)
+	return err == nil
+}
+
+func (r *Range) String() string {
+	return r.raw
+}
+
+func (r *Range) Test(v *Version) bool {
+	for _, set := range r.set {
+		if testSet(set, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Satisfies reports whether version is matched by rng. Unparseable input
+// never satisfies.
+func Satisfies(version, rng string) bool {
+	v, err := Parse(version)
+	if err != nil {
+		return false
+	}
+	r, err := ParseRange(rng)
+	if err != nil {
+		return false
+	}
+	return r.Test(v)
+}
+
+// MaxSatisfying returns the highest of versions matched by r, or "" when
+// none of them match.
+func MaxSatisfying(versions []string, r *Range) string {
+	var best *Version
+	bestRaw := ""
+	for _, raw := range versions {
+		v, err := Parse(raw)
+		if err != nil || !r.Test(v) {
+			continue
+		}
+		if best == nil || Compare(v, best) > 0 {
+			best = v
+			bestRaw = raw
+		}
+	}
+	return bestRaw
+}
+
+func testSet(set []comparator, v *Version) bool {
+	for _, c := range set {
+		if !c.test(v) {
+			return false
+		}
+	}
+	if !v.IsPrerelease() {
+		return true
+	}
+	// A prerelease only matches when some comparator in the set opts into
+	// prereleases of the same major.minor.patch tuple.
+	for _, c := range set {
+		if c.v == nil {
+			continue
+		}
+		if c.v.IsPrerelease() && c.v.sameTuple(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c comparator) test(v *Version) bool {
+	if c.v == nil {
+		return true
+	}
+	cmp := Compare(v, c.v)
+	switch c.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return cmp == 0
+}
+
+func parseComparatorSet(s string) ([]comparator, error) {
+	s = strings.TrimSpace(operatorSpace.ReplaceAllString(s, "$1"))
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return []comparator{{}}, nil
+	}
+	if len(fields) == 3 && fields[1] == "-" {
+		return parseHyphen(fields[0], fields[2])
+	}
+	var set []comparator
+	for _, field := range fields {
+		cs, err := parseComparator(field)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, cs...)
+	}
+	return set, nil
+}
+
+type partial struct {
+	major, minor, patch uint64
+	xMajor, xMinor      bool
+	xPatch              bool
+	pre                 []string
+}
+
+func isWildcard(s string) bool {
+	return s == "" || s == "x" || s == "X" || s == "*"
+}
+
+func parsePartial(s string) (partial, error) {
+	raw := s
+	s = strings.TrimLeft(s, "=v")
+	var p partial
+	if idx := strings.Index(s, "+"); idx != -1 {
+		s = s[:idx]
+	}
+	if idx := strings.Index(s, "-"); idx != -1 {
+		p.pre = strings.Split(s[idx+1:], ".")
+		s = s[:idx]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return p, fmt.Errorf("invalid version %q", raw)
+	}
+	for len(parts) < 3 {
+		parts = append(parts, "")
+	}
+	wild := []*bool{&p.xMajor, &p.xMinor, &p.xPatch}
+	nums := []*uint64{&p.major, &p.minor, &p.patch}
+	for i, part := range parts {
+		if i > 0 && *wild[i-1] {
+			*wild[i] = true
+			continue
+		}
+		if isWildcard(part) {
+			*wild[i] = true
+			continue
+		}
+		n, err := parseNumber(part)
+		if err != nil {
+			return p, fmt.Errorf("invalid version %q", raw)
+		}
+		*nums[i] = n
+	}
+	if p.xPatch {
+		p.pre = nil
+	}
+	return p, nil
+}
+
+func version(major, minor, patch uint64, pre ...string) *Version {
+	return &Version{Major: major, Minor: minor, Patch: patch, Prerelease: pre}
+}
+
+// zero is the lowest prerelease of a tuple, used for exclusive upper bounds so
+// that prereleases of the next release are not matched.
+var zero = []string{"0"}
+
+func parseComparator(s string) ([]comparator, error) {
+	op := ""
+	for _, candidate := range []string{"~>", ">=", "<=", "~", "^", ">", "<", "="} {
+		if strings.HasPrefix(s, candidate) {
+			op = candidate
+			break
+		}
+	}
+	p, err := parsePartial(s[len(op):])
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case "~", "~>":
+		return tilde(p), nil
+	case "^":
+		return caret(p), nil
+	}
+	return xrange(op, p), nil
+}
+
+func tilde(p partial) []comparator {
+	switch {
+	case p.xMajor:
+		return []comparator{{}}
+	case p.xMinor:
+		return []comparator{
+			{">=", version(p.major, 0, 0)},
+			{"<", version(p.major+1, 0, 0, zero...)},
+		}
+	case p.xPatch:
+		return []comparator{
+			{">=", version(p.major, p.minor, 0)},
+			{"<", version(p.major, p.minor+1, 0, zero...)},
+		}
+	}
+	return []comparator{
+		{">=", version(p.major, p.minor, p.patch, p.pre...)},
+		{"<", version(p.major, p.minor+1, 0, zero...)},
+	}
+}
+
+func caret(p partial) []comparator {
+	switch {
+	case p.xMajor:
+		return []comparator{{}}
+	case p.xMinor:
+		return []comparator{
+			{">=", version(p.major, 0, 0)},
+			{"<", version(p.major+1, 0, 0, zero...)},
+		}
+	case p.xPatch:
+		if p.major == 0 {
+			return []comparator{
+				{">=", version(0, p.minor, 0)},
+				{"<", version(0, p.minor+1, 0, zero...)},
+			}
+		}
+		return []comparator{
+			{">=", version(p.major, p.minor, 0)},
+			{"<", version(p.major+1, 0, 0, zero...)},
+		}
+	}
+	lower := comparator{">=", version(p.major, p.minor, p.patch, p.pre...)}
+	switch {
+	case p.major == 0 && p.minor == 0:
+		return []comparator{lower, {"<", version(0, 0, p.patch+1, zero...)}}
+	case p.major == 0:
+		return []comparator{lower, {"<", version(0, p.minor+1, 0, zero...)}}
+	}
+	return []comparator{lower, {"<", version(p.major+1, 0, 0, zero...)}}
+}
+
+func xrange(op string, p partial) []comparator {
+	if !p.xPatch {
+		if op == "" {
+			op = "="
+		}
+		return []comparator{{op, version(p.major, p.minor, p.patch, p.pre...)}}
+	}
+	if p.xMajor {
+		if op == ">" || op == "<" {
+			return []comparator{{"<", version(0, 0, 0, zero...)}}
+		}
+		return []comparator{{}}
+	}
+	if op == "" || op == "=" {
+		if p.xMinor {
+			return []comparator{
+				{">=", version(p.major, 0, 0)},
+				{"<", version(p.major+1, 0, 0, zero...)},
+			}
+		}
+		return []comparator{
+			{">=", version(p.major, p.minor, 0)},
+			{"<", version(p.major, p.minor+1, 0, zero...)},
+		}
+	}
+	major, minor := p.major, p.minor
+	if p.xMinor {
+		minor = 0
+	}
+	switch op {
+	case ">":
+		op = ">="
+		if p.xMinor {
+			major++
+		} else {
+			minor++
+		}
+	case "<=":
+		op = "<"
+		if p.xMinor {
+			major++
+		} else {
+			minor++
+		}
+	}
+	if op == "<" {
+		return []comparator{{op, version(major, minor, 0, zero...)}}
+	}
+	return []comparator{{op, version(major, minor, 0)}}
+}
+
+func parseHyphen(from, to string) ([]comparator, error) {
+	lo, err := parsePartial(from)
+	if err != nil {
+		return nil, err
+	}
+	hi, err := parsePartial(to)
+	if err != nil {
+		return nil, err
+	}
+	var set []comparator
+	switch {
+	case lo.xMajor:
+	case lo.xMinor:
+		set = append(set, comparator{">=", version(lo.major, 0, 0)})
+	case lo.xPatch:
+		set = append(set, comparator{">=", version(lo.major, lo.minor, 0)})
+	default:
+		set = append(set, comparator{">=", version(lo.major, lo.minor, lo.patch, lo.pre...)})
+	}
+	switch {
+	case hi.xMajor:
+	case hi.xMinor:
+		set = append(set, comparator{"<", version(hi.major+1, 0, 0, zero...)})
+	case hi.xPatch:
+		set = append(set, comparator{"<", version(hi.major, hi.minor+1, 0, zero...)})
+	default:
+		set = append(set, comparator{"<=", version(hi.major, hi.minor, hi.patch, hi.pre...)})
+	}
+	if len(set) == 0 {
+		set = append(set, comparator{})
+	}
+	return set, nil
+}
@@ -0,0 +1,127 @@
+package semver
+
+import "testing"
+
+func TestRangeTest(t *testing.T) {
+	tests := []struct {
+		rng     string
+		version string
+		want    bool
+	}{
+		// caret
+		{"^1.2.3", "1.2.3", true},
+		{"^1.2.3", "1.9.0", true},
+		{"^1.2.3", "2.0.0", false},
+		{"^1.2.3", "1.2.2", false},
+		{"^0.2.3", "0.2.9", true},
+		{"^0.2.3", "0.3.0", false},
+		{"^0.0.3", "0.0.3", true},
+		{"^0.0.3", "0.0.4", false},
+		{"^0.0", "0.0.9", true},
+		{"^0.0", "0.1.0", false},
+		{"^0.x", "0.9.0", true},
+		{"^0.x", "1.0.0", false},
+		{"^1.x", "1.5.0", true},
+		{"^1.x", "2.0.0", false},
+		// tilde
+		{"~1.2.3", "1.2.9", true},
+		{"~1.2.3", "1.3.0", false},
+		{"~1.2", "1.2.0", true},
+		{"~1.2", "1.3.0", false},
+		{"~1", "1.9.9", true},
+		{"~1", "2.0.0", false},
+		{"~0.2.3", "0.2.4", true},
+		{"~0.2.3", "0.3.0", false},
+		{"~0.0.1", "0.0.9", true},
+		{"~0.0.1", "0.1.0", false},
+		{"~>1.2.3", "1.2.5", true},
+		// hyphen ranges
+		{"1.2.3 - 2.3.4", "1.2.3", true},
+		{"1.2.3 - 2.3.4", "2.3.4", true},
+		{"1.2.3 - 2.3.4", "2.3.5", false},
+		{"1.2 - 2.3.4", "1.2.0", true},
+		{"1.2 - 2.3.4", "1.1.9", false},
+		{"1.2.3 - 2.3", "2.3.9", true},
+		{"1.2.3 - 2.3", "2.4.0", false},
+		{"1.2.3 - 2", "2.9.9", true},
+		{"1.2.3 - 2", "3.0.0", false},
+		// x-ranges
+		{"*", "3.1.4", true},
+		{"", "0.0.1", true},
+		{"x", "1.0.0", true},
+		{"1.x", "1.4.0", true},
+		{"1.x", "2.0.0", false},
+		{"1.2.x", "1.2.7", true},
+		{"1.2.x", "1.3.0", false},
+		{"1", "1.0.0", true},
+		{"1.2", "1.2.99", true},
+		{">1.x", "2.0.0", true},
+		{">1.x", "1.9.9", false},
+		{"<=1.2", "1.2.9", true},
+		{"<=1.2", "1.3.0", false},
+		{">=1.2.x", "1.2.0", true},
+		// unions
+		{"1.2.3 || 2.x", "1.2.3", true},
+		{"1.2.3 || 2.x", "2.5.0", true},
+		{"1.2.3 || 2.x", "1.2.4", false},
+		{"<1.0.0 || >=3.0.0", "0.9.0", true},
+		{"<1.0.0 || >=3.0.0", "2.0.0", false},
+		{"^1.0.0 || ^2.0.0", "2.1.0", true},
+		// comparators
+		{">= 1.2.3 < 1.3.0", "1.2.5", true},
+		{">=1.2.3 <1.3.0", "1.3.0", false},
+		{"=1.2.3", "1.2.3", true},
+		{"v1.2.3", "1.2.3", true},
+		// prereleases only match a range naming the same tuple
+		{"^1.2.3", "1.3.0-beta.1", false},
+		{"^1.2.3-beta.1", "1.2.3-beta.2", true},
+		{"^1.2.3-beta.1", "1.2.3-alpha.1", false},
+		{"^1.2.3-beta.1", "1.3.0-beta.1", false},
+		{"^1.2.3-beta.1", "1.3.0", true},
+		{"*", "1.0.0-rc.1", false},
+		{">=1.0.0", "2.0.0-rc.1", false},
+		{"<2.0.0", "2.0.0-rc.1", false},
+		{"1.0.0-rc.1 - 1.0.0", "1.0.0-rc.2", true},
+		{"~1.2.3-beta.2", "1.2.3-beta.4", true},
+		{"~1.2.3-beta.2", "1.2.4-beta.2", false},
+	}
+	for _, tt := range tests {
+		r, err := ParseRange(tt.rng)
+		if err != nil {
+			t.Errorf("ParseRange(%q): %v", tt.rng, err)
+			continue
+		}
+		if got := r.Test(MustParse(tt.version)); got != tt.want {
+			t.Errorf("%q.Test(%s) = %v, want %v", tt.rng, tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestParseRangeInvalid(t *testing.T) {
+	for _, rng := range []string{"^1.2.3.4", "1.2.foo", ">=a", "~1.b"} {
+		if _, err := ParseRange(rng); err == nil {
+			t.Errorf("ParseRange(%q) succeeded, want an error", rng)
+		}
+	}
+}
+
+func TestMaxSatisfying(t *testing.T) {
+	versions := []string{"1.0.0", "1.2.0", "1.10.0", "2.0.0-beta.1", "2.0.0", "2.1.0"}
+	tests := []struct {
+		rng  string
+		want string
+	}{
+		{"^1.0.0", "1.10.0"},
+		{"~1.2.0", "1.2.0"},
+		{"1.0.0 - 1.5.0", "1.2.0"},
+		{"^1.0.0 || ^2.0.0", "2.1.0"},
+		{"^2.0.0-beta.0", "2.1.0"},
+		{"<2.0.0", "1.10.0"},
+		{"^3.0.0", ""},
+	}
+	for _, tt := range tests {
+		if got := MaxSatisfying(versions, MustParseRange(tt.rng)); got != tt.want {
+			t.Errorf("MaxSatisfying(%q) = %q, want %q", tt.rng, got, tt.want)
+		}
+	}
+}
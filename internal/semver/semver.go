@@ -0,0 +1,155 @@
+package semver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Version struct {
+	Major      uint64
+	Minor      uint64
+	Patch      uint64
+	Prerelease []string
+	Build      []string
+}
+
+func Parse(s string) (*Version, error) {
+	raw := s
+	s = strings.TrimSpace(s)
+	s = strings.TrimLeft(s, "=v")
+	s = strings.TrimSpace(s)
+	var build, pre []string
+	if idx := strings.Index(s, "+"); idx != -1 {
+		build = strings.Split(s[idx+1:], ".")
+		s = s[:idx]
+	}
+	if idx := strings.Index(s, "-"); idx != -1 {
+		pre = strings.Split(s[idx+1:], ".")
+		s = s[:idx]
+		for _, id := range pre {
+			if id == "" {
+				return nil, fmt.Errorf("invalid version %q", raw)
+			}
+		}
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid version %q", raw)
+	}
+	nums := make([]uint64, 3)
+	for i, part := range parts {
+		n, err := parseNumber(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q", raw)
+		}
+		nums[i] = n
+	}
+	return &Version{
+		Major:      nums[0],
+		Minor:      nums[1],
+		Patch:      nums[2],
+		Prerelease: pre,
+		Build:      build,
+	}, nil
+}
+
+func MustParse(s string) *Version {
+	v, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func Valid(s string) bool {
+	_, err := Parse(s)
+	return err == nil
+}
+
+func parseNumber(s string) (uint64, error) {
+	if s == "" {
+		return 0, fmt.Errorf("empty number")
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("invalid number %q", s)
+		}
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+func (v *Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.Prerelease) > 0 {
+		s += "-" + strings.Join(v.Prerelease, ".")
+	}
+	if len(v.Build) > 0 {
+		s += "+" + strings.Join(v.Build, ".")
+	}
+	return s
+}
+
+func (v *Version) IsPrerelease() bool {
+	return len(v.Prerelease) > 0
+}
+
+// Compare orders versions by precedence; build metadata is ignored.
+func Compare(a, b *Version) int {
+	if c := compareNumber(a.Major, b.Major); c != 0 {
+		return c
+	}
+	if c := compareNumber(a.Minor, b.Minor); c != 0 {
+		return c
+	}
+	if c := compareNumber(a.Patch, b.Patch); c != 0 {
+		return c
+	}
+	return comparePrerelease(a.Prerelease, b.Prerelease)
+}
+
+func compareNumber(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func comparePrerelease(a, b []string) int {
+	if len(a) == 0 && len(b) == 0 {
+		return 0
+	}
+	if len(a) == 0 {
+		return 1
+	}
+	if len(b) == 0 {
+		return -1
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compareIdentifier(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return compareNumber(uint64(len(a)), uint64(len(b)))
+}
+
+func compareIdentifier(a, b string) int {
+	an, aErr := parseNumber(a)
+	bn, bErr := parseNumber(b)
+	switch {
+	case aErr == nil && bErr == nil:
+		return compareNumber(an, bn)
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func (v *Version) sameTuple(o *Version) bool {
+	return v.Major == o.Major && v.Minor == o.Minor && v.Patch == o.Patch
+}
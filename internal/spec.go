@@ -0,0 +1,235 @@
+package internal
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/chann44/tidy/internal/semver"
+)
+
+// parseAlias splits an "npm:name@range" alias spec into the real package name
+// and its range. Scoped targets such as "npm:@scope/pkg@2" are supported; a
+// missing range means any version.
+func parseAlias(spec string) (string, string, bool) {
+	if !strings.HasPrefix(spec, "npm:") {
+		return "", "", false
+	}
+	name, rng := splitDescriptor(strings.TrimPrefix(spec, "npm:"))
+	if name == "" {
+		return "", "", false
+	}
+	return name, rng, true
+}
+
+// gitSpec is a dependency fetched from a git repository. The ref is either a
+// committish or, with "#semver:", a range matched against the repo's tags.
+type gitSpec struct {
+	url        string
+	committish string
+	semver     string
+}
+
+var gitHosts = map[string]string{
+	"github:":    "https://github.com/",
+	"gitlab:":    "https://gitlab.com/",
+	"bitbucket:": "https://bitbucket.org/",
+}
+
+// githubShorthand matches "org/repo" and "org/repo#ref".
+var githubShorthand = regexp.MustCompile(`^[^./@:\s][^/:\s]*/[^/:#\s]+(#.*)?$`)
+
+// parseGitSpec recognizes "github:org/repo#ref", "org/repo", "git+https://",
+// "git+ssh://", "git+file://", "git://" and https URLs ending in .git.
+func parseGitSpec(spec string) (gitSpec, bool) {
+	repo, fragment, _ := strings.Cut(spec, "#")
+	var url string
+	switch {
+	case strings.HasPrefix(repo, "git+"):
+		url = strings.TrimPrefix(repo, "git+")
+	case strings.HasPrefix(repo, "git://"):
+		url = repo
+	case (strings.HasPrefix(repo, "https://") || strings.HasPrefix(repo, "http://")) && strings.HasSuffix(repo, ".git"):
+		url = repo
+	case githubShorthand.MatchString(spec):
+		url = "https://github.com/" + repo + ".git"
+	default:
+		for prefix, base := range gitHosts {
+			if strings.HasPrefix(repo, prefix) {
+				url = base + strings.TrimSuffix(strings.TrimPrefix(repo, prefix), ".git") + ".git"
+			}
+		}
+	}
+	// Anything starting with - would reach git as an option.
+	if url == "" || strings.HasPrefix(url, "-") || strings.HasPrefix(fragment, "-") {
+		return gitSpec{}, false
+	}
+	g := gitSpec{url: url}
+	if rng, ok := strings.CutPrefix(fragment, "semver:"); ok {
+		g.semver = rng
+	} else {
+		g.committish = fragment
+	}
+	return g, true
+}
+
+// resolved is the URL recorded for the package, pinned to commit.
+func (g gitSpec) resolved(commit string) string {
+	url := g.url
+	if !strings.HasPrefix(url, "git://") {
+		url = "git+" + url
+	}
+	return url + "#" + commit
+}
+
+// fileSpec is a dependency on the local filesystem: a directory or tarball
+// behind "file:", or a directory symlinked into place with "link:". path is
+// relative to the project root unless it was given as an absolute path.
+type fileSpec struct {
+	path    string
+	link    bool
+	tarball bool
+}
+
+// parseFileSpec recognizes "file:" and "link:" specs as well as bare
+// "./", "../" and "/" paths, which npm treats as "file:".
+func parseFileSpec(spec string) (fileSpec, bool) {
+	var f fileSpec
+	switch {
+	case strings.HasPrefix(spec, "link:"):
+		f.path, f.link = strings.TrimPrefix(spec, "link:"), true
+	case strings.HasPrefix(spec, "file:"):
+		f.path = strings.TrimPrefix(spec, "file:")
+	case strings.HasPrefix(spec, "./"), strings.HasPrefix(spec, "../"), strings.HasPrefix(spec, "/"):
+		f.path = spec
+	default:
+		return fileSpec{}, false
+	}
+	f.path = path.Clean(filepath.ToSlash(f.path))
+	f.tarball = !f.link && isTarballName(f.path)
+	return f, true
+}
+
+// String is the normalized spec recorded for the package.
+func (f fileSpec) String() string {
+	if f.link {
+		return "link:" + f.path
+	}
+	return "file:" + f.path
+}
+
+// rebase resolves a relative path against dir, the root-relative directory
+// of the package that declared it.
+func (f fileSpec) rebase(dir string) fileSpec {
+	if !path.IsAbs(f.path) {
+		f.path = path.Join(dir, f.path)
+	}
+	return f
+}
+
+// isTarballURL reports whether spec downloads a tarball directly rather than
+// resolving against the registry or cloning a git repository.
+func isTarballURL(spec string) bool {
+	if !strings.HasPrefix(spec, "https://") && !strings.HasPrefix(spec, "http://") {
+		return false
+	}
+	_, isGit := parseGitSpec(spec)
+	return !isGit
+}
+
+// isRegistryTarball reports whether deps was resolved from a registry, whose
+// tarballs live at <registry>/<name>/-/<name>-<version>.tgz.
+func isRegistryTarball(deps Deps) bool {
+	base := deps.Name[strings.LastIndex(deps.Name, "/")+1:]
+	return strings.HasSuffix(deps.Tarball, "/"+deps.Name+"/-/"+base+"-"+deps.Version+".tgz")
+}
+
+// isPinnedSpec reports whether the lock pins spec to what it first resolved
+// to, because the source behind it can move: a git ref or a tarball URL.
+func isPinnedSpec(spec string) bool {
+	_, isGit := parseGitSpec(spec)
+	return isGit || isTarballURL(spec)
+}
+
+func isTarballName(name string) bool {
+	return strings.HasSuffix(name, ".tgz") || strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tar")
+}
+
+// ParsePackageArg splits a package named on the command line, as in "react",
+// "react@next", "@types/node@^20" or "r17@npm:react@17". Git, file and
+// tarball specs given on their own return no name; it comes from the package.
+func ParsePackageArg(arg string) (string, string) {
+	if _, ok := parseGitSpec(arg); ok {
+		return "", arg
+	}
+	if _, ok := parseFileSpec(arg); ok || isTarballURL(arg) {
+		return "", arg
+	}
+	return splitDescriptor(arg)
+}
+
+// SaveSpec resolves a package argument to the name and spec to write into
+// package.json: prefix ("^", "~" or "" for exact) around the version it
+// resolves to, so the manifest never records a moving target like a dist-tag.
+// Like npm, a typed range is kept instead when the prefixed version would
+// allow versions outside of it; exact versions get the prefix.
+func SaveSpec(arg, prefix string) (string, string, error) {
+	name, spec := ParsePackageArg(arg)
+	if f, ok := parseFileSpec(spec); ok {
+		spec = f.String()
+	}
+	if name == "" {
+		manifest, _, err := fetchEdgeManifest(name, spec)
+		if err != nil {
+			return "", "", err
+		}
+		if manifest.Name == "" {
+			return "", "", fmt.Errorf("%s has no name in its package.json", arg)
+		}
+		return manifest.Name, spec, nil
+	}
+	target, rng := name, spec
+	alias := false
+	if t, r, ok := parseAlias(spec); ok {
+		target, rng, alias = t, r, true
+	} else if strings.Contains(spec, ":") || isPinnedSpec(spec) {
+		return name, spec, nil
+	}
+	if rng == "" {
+		rng = "latest"
+	}
+	manifest, err := FetchManifest(target, rng)
+	if err != nil {
+		return "", "", err
+	}
+	saved := prefix + manifest.Version
+	if _, err := semver.Parse(rng); err != nil {
+		if r, err := semver.ParseRange(rng); err == nil && !prefixWithin(prefix, manifest.Version, r) {
+			saved = rng
+		}
+	}
+	if alias {
+		saved = "npm:" + target + "@" + saved
+	}
+	return name, saved, nil
+}
+
+// prefixWithin reports whether every version prefix+version allows is in r,
+// checked at the highest version the prefix allows.
+func prefixWithin(prefix, version string, r *semver.Range) bool {
+	v, err := semver.Parse(version)
+	if err != nil {
+		return false
+	}
+	const maxPart = 1<<53 - 1
+	highest := semver.Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch}
+	switch {
+	case prefix == "^" && v.Major > 0:
+		highest.Minor, highest.Patch = maxPart, maxPart
+	case prefix == "^" && v.Minor > 0, prefix == "~":
+		highest.Patch = maxPart
+	}
+	return r.Test(v) && r.Test(&highest)
+}
@@ -0,0 +1,72 @@
+package internal
+
+import "testing"
+
+func TestParseAlias(t *testing.T) {
+	tests := []struct {
+		spec   string
+		name   string
+		rng    string
+		parsed bool
+	}{
+		{"npm:react@^17.0.0", "react", "^17.0.0", true},
+		{"npm:@scope/pkg@2", "@scope/pkg", "2", true},
+		{"npm:react", "react", "", true},
+		{"npm:@scope/pkg", "@scope/pkg", "", true},
+		{"npm:", "", "", false},
+		{"^17.0.0", "", "", false},
+		{"github:org/react", "", "", false},
+	}
+	for _, tt := range tests {
+		name, rng, ok := parseAlias(tt.spec)
+		if name != tt.name || rng != tt.rng || ok != tt.parsed {
+			t.Errorf("parseAlias(%q) = %q, %q, %v; want %q, %q, %v", tt.spec, name, rng, ok, tt.name, tt.rng, tt.parsed)
+		}
+	}
+}
+
+func TestParsePackageArgAlias(t *testing.T) {
+	tests := []struct {
+		arg  string
+		name string
+		spec string
+	}{
+		{"r17@npm:react@17", "r17", "npm:react@17"},
+		{"@my/r@npm:@scope/react@^1", "@my/r", "npm:@scope/react@^1"},
+		{"react@17", "react", "17"},
+	}
+	for _, tt := range tests {
+		if name, spec := ParsePackageArg(tt.arg); name != tt.name || spec != tt.spec {
+			t.Errorf("ParsePackageArg(%q) = %q, %q; want %q, %q", tt.arg, name, spec, tt.name, tt.spec)
+		}
+	}
+}
+
+// Aliases are installed under the name they were declared with, while the
+// node keeps the real package name.
+func TestHoistAliases(t *testing.T) {
+	res := newResolved()
+	res.Packages["react@17.0.2"] = Deps{Name: "react", Version: "17.0.2", Dependencies: map[string]string{}}
+	res.Packages["react@18.2.0"] = Deps{Name: "react", Version: "18.2.0", Dependencies: map[string]string{}}
+	res.Packages["string-width@4.2.3"] = Deps{Name: "string-width", Version: "4.2.3", Dependencies: map[string]string{}}
+	res.Packages["a@1.0.0"] = Deps{Name: "a", Version: "1.0.0", Dependencies: map[string]string{"sw": "string-width@4.2.3"}}
+	res.Dependencies["r17"] = "react@17.0.2"
+	res.Dependencies["react"] = "react@18.2.0"
+	res.Dependencies["a"] = "a@1.0.0"
+
+	layout := Hoist(res)
+	want := map[string]string{
+		"node_modules/r17":   "react@17.0.2",
+		"node_modules/react": "react@18.2.0",
+		"node_modules/a":     "a@1.0.0",
+		"node_modules/sw":    "string-width@4.2.3",
+	}
+	for dir, key := range want {
+		if layout[dir] != key {
+			t.Errorf("%s = %q, want %q", dir, layout[dir], key)
+		}
+	}
+	if len(layout) != len(want) {
+		t.Errorf("layout = %v, want %v", layout, want)
+	}
+}
@@ -0,0 +1,283 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// The store keeps each unpacked tarball once, in a content directory named
+// after the tarball's sha512, so two tarballs can never share an entry. An
+// index maps name@version, or the URL of a tarball dependency, to the
+// integrity of the tarball it was installed from, or for entries of the old
+// store that could not be moved, to "legacy:" and where they still are.
+const (
+	storeContentDir   = "content"
+	storeIndexDir     = "index"
+	storeTempDir      = "tmp"
+	legacyIndexPrefix = "legacy:"
+	migratedMarker    = ".migrated"
+)
+
+var (
+	storeMigrateOnce sync.Once
+	storeMigrateErr  error
+)
+
+// contentDir is where the tarball with integrity is unpacked. Only sha512 is
+// used to address content; other hashes go through the index.
+func contentDir(storeDir, integrity string) (string, bool) {
+	digest, ok := parseIntegrity(integrity)["sha512"]
+	if !ok {
+		return "", false
+	}
+	sum, err := base64.StdEncoding.DecodeString(digest)
+	if err != nil || len(sum) != sha512.Size {
+		return "", false
+	}
+	name := hex.EncodeToString(sum)
+	return filepath.Join(storeDir, storeContentDir, "sha512", name[:2], name[2:]), true
+}
+
+func urlIndexKey(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return "url/" + hex.EncodeToString(sum[:])
+}
+
+func indexPath(storeDir, key string) string {
+	return filepath.Join(storeDir, storeIndexDir, filepath.FromSlash(key))
+}
+
+// storeIndex returns the integrity recorded for key, if any.
+func storeIndex(storeDir, key string) string {
+	data, err := os.ReadFile(indexPath(storeDir, key))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func writeStoreIndex(storeDir, key, integrity string) error {
+	target := indexPath(storeDir, key)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	tmp := target + ".tmp"
+	if err := os.WriteFile(tmp, []byte(integrity+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, target)
+}
+
+// storeLookup finds the unpacked tarball for key that has the expected
+// integrity. A sha512 finds it directly; anything else, or no expectation at
+// all, has to be recorded in the index for key. Legacy entries were unpacked
+// before integrity was checked, so they only serve lookups that expect none;
+// otherwise they are a miss and the download replaces them.
+func storeLookup(storeDir, key, expected string) (string, bool) {
+	if dir, ok := contentDir(storeDir, expected); ok {
+		if _, err := os.Stat(dir); err == nil {
+			return dir, true
+		}
+	}
+	if key == "" {
+		return "", false
+	}
+	indexed := storeIndex(storeDir, key)
+	if legacy, ok := strings.CutPrefix(indexed, legacyIndexPrefix); ok {
+		if expected != "" {
+			return "", false
+		}
+		dir := filepath.Join(storeDir, filepath.FromSlash(legacy))
+		if _, err := os.Stat(dir); err != nil {
+			return "", false
+		}
+		return dir, true
+	}
+	if indexed == "" || !integrityMatches(expected, indexed) {
+		return "", false
+	}
+	dir, ok := contentDir(storeDir, indexed)
+	if !ok {
+		return "", false
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return "", false
+	}
+	return dir, true
+}
+
+// storeIntegrity is the verified integrity recorded for key, if any.
+func storeIntegrity(storeDir, key string) string {
+	integrity := storeIndex(storeDir, key)
+	if strings.HasPrefix(integrity, legacyIndexPrefix) {
+		return ""
+	}
+	return integrity
+}
+
+// storeTarball unpacks r into the store after checking it against expected
+// and records it in the index under key, unless key is empty.
+func storeTarball(storeDir, key, expected string, r io.Reader) (string, error) {
+	if err := os.MkdirAll(filepath.Join(storeDir, storeTempDir), 0755); err != nil {
+		return "", err
+	}
+	tempDir, err := os.MkdirTemp(filepath.Join(storeDir, storeTempDir), "extract-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tempDir)
+	extracted := filepath.Join(tempDir, "package")
+	integrity, err := extractTarball(r, expected, extracted)
+	if err != nil {
+		return "", err
+	}
+	dir, _ := contentDir(storeDir, integrity)
+	if err := moveToContent(extracted, dir); err != nil {
+		return "", err
+	}
+	if key != "" {
+		if err := writeStoreIndex(storeDir, key, integrity); err != nil {
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+// moveToContent puts an unpacked tarball at its content directory. When the
+// same content is there already, from another package or install, that copy
+// is kept.
+func moveToContent(src, dir string) error {
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dir); err != nil {
+		if _, statErr := os.Stat(dir); statErr == nil {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// migrateStore moves the entries of the old store layout, which were keyed
+// by name@version parsed from the tarball URL, by tarball URL or by local
+// tarball hash, into the content store when their integrity was recorded.
+// Older entries stay where they are and are indexed as legacy entries. Only
+// the old integrity files are ever deleted, and half-extracted directories,
+// which another tidy may still be writing, are left alone. A marker in the
+// index keeps this from running again.
+func migrateStore(storeDir string) error {
+	marker := filepath.Join(storeDir, storeIndexDir, migratedMarker)
+	if _, err := os.Stat(marker); err == nil {
+		return nil
+	}
+	entries, err := os.ReadDir(storeDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		switch {
+		case name == storeContentDir || name == storeIndexDir || name == storeTempDir || name == "git" || name == "local":
+			continue
+		case name == "url":
+			err = migrateEntries(storeDir, name, func(rel string) string { return rel })
+		case name == "tarball":
+			err = migrateEntries(storeDir, name, func(string) string { return "" })
+		case strings.HasPrefix(name, "@") && entry.IsDir():
+			err = migrateEntries(storeDir, name, func(rel string) string {
+				return manifestKey(filepath.Join(storeDir, filepath.FromSlash(rel)))
+			})
+		case entry.IsDir():
+			err = migrateEntry(storeDir, name, manifestKey(filepath.Join(storeDir, name)))
+		case strings.HasSuffix(name, ".integrity"):
+			// Left behind by an entry migrated above.
+			err = os.Remove(filepath.Join(storeDir, name))
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(marker), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(marker, nil, 0644)
+}
+
+// migrateEntries migrates every entry of the old store directory rel, each
+// indexed under the key returned for its path relative to the store.
+func migrateEntries(storeDir, rel string, key func(string) string) error {
+	entries, err := os.ReadDir(filepath.Join(storeDir, rel))
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		entryRel := rel + "/" + entry.Name()
+		if !entry.IsDir() {
+			if strings.HasSuffix(entry.Name(), ".integrity") {
+				os.Remove(filepath.Join(storeDir, filepath.FromSlash(entryRel)))
+			}
+			continue
+		}
+		if err := migrateEntry(storeDir, entryRel, key(entryRel)); err != nil {
+			return err
+		}
+	}
+	// Removed only once nothing is left in it.
+	os.Remove(filepath.Join(storeDir, rel))
+	return nil
+}
+
+// migrateEntry moves the old entry at rel to its content directory using the
+// integrity recorded next to it, or without one indexes it where it is. The
+// entry is indexed under key unless that is empty.
+func migrateEntry(storeDir, rel, key string) error {
+	if strings.HasSuffix(rel, ".tmp") {
+		return nil
+	}
+	dir := filepath.Join(storeDir, filepath.FromSlash(rel))
+	data, _ := os.ReadFile(dir + ".integrity")
+	integrity := strings.TrimSpace(string(data))
+	content, ok := contentDir(storeDir, integrity)
+	if !ok {
+		if key == "" || storeIndex(storeDir, key) != "" {
+			return nil
+		}
+		return writeStoreIndex(storeDir, key, legacyIndexPrefix+rel)
+	}
+	if err := moveToContent(dir, content); err != nil {
+		return err
+	}
+	// Still there when the content store already had the same tarball.
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	os.Remove(dir + ".integrity")
+	if key == "" {
+		return nil
+	}
+	return writeStoreIndex(storeDir, key, integrity)
+}
+
+// manifestKey is the name@version of an unpacked package, read from its
+// package.json since old store names could have the version wrong.
+func manifestKey(dir string) string {
+	manifest, err := readManifestFile(filepath.Join(dir, "package.json"))
+	if err != nil || manifest.Name == "" {
+		return ""
+	}
+	return PackageKey(manifest.Name, manifest.Version)
+}
@@ -0,0 +1,86 @@
+package internal
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeLegacyEntry(t *testing.T, dir, name, version, integrity string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	manifest := `{"name": "` + name + `", "version": "` + version + `"}`
+	if err := os.WriteFile(filepath.Join(dir, "package.json"), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if integrity != "" {
+		if err := os.WriteFile(dir+".integrity", []byte(integrity+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMigrateStore(t *testing.T) {
+	storeDir := t.TempDir()
+	sum := sha512.Sum512([]byte("tarball"))
+	integrity := "sha512-" + base64.StdEncoding.EncodeToString(sum[:])
+	// Recorded integrity: moved into the content store.
+	writeLegacyEntry(t, filepath.Join(storeDir, "@acme", "core@2.0.0"), "@acme/core", "2.0.0", integrity)
+	// No integrity, and a version mangled by the old URL parsing: left in place.
+	writeLegacyEntry(t, filepath.Join(storeDir, "left@1"), "left", "1.0.0-beta.1", "")
+	writeLegacyEntry(t, filepath.Join(storeDir, "url", "abc"), "remote", "1.0.0", "")
+	// Not the store's to delete.
+	writeLegacyEntry(t, filepath.Join(storeDir, "right@1.0.0.tmp"), "right", "1.0.0", "")
+	if err := os.WriteFile(filepath.Join(storeDir, "notes.txt"), []byte("mine"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrateStore(storeDir); err != nil {
+		t.Fatal(err)
+	}
+
+	content, _ := contentDir(storeDir, integrity)
+	if dir, ok := storeLookup(storeDir, "@acme/core@2.0.0", ""); !ok || dir != content {
+		t.Errorf("@acme/core@2.0.0 = %q, %v; want %q", dir, ok, content)
+	}
+	if _, err := os.Stat(filepath.Join(storeDir, "@acme", "core@2.0.0")); !os.IsNotExist(err) {
+		t.Errorf("migrated entry is still in the old layout")
+	}
+	if _, err := os.Stat(filepath.Join(storeDir, "@acme", "core@2.0.0.integrity")); !os.IsNotExist(err) {
+		t.Errorf("integrity file of a migrated entry was kept")
+	}
+	if dir, ok := storeLookup(storeDir, "left@1.0.0-beta.1", ""); !ok || dir != filepath.Join(storeDir, "left@1") {
+		t.Errorf("left@1.0.0-beta.1 = %q, %v; want the legacy entry", dir, ok)
+	}
+	// Unverified, so it cannot stand in for a package locked with integrity.
+	other := sha512.Sum512([]byte("left"))
+	if dir, ok := storeLookup(storeDir, "left@1.0.0-beta.1", "sha512-"+base64.StdEncoding.EncodeToString(other[:])); ok {
+		t.Errorf("left@1.0.0-beta.1 with integrity = %q; want a miss", dir)
+	}
+	if dir, ok := storeLookup(storeDir, "url/abc", ""); !ok || dir != filepath.Join(storeDir, "url", "abc") {
+		t.Errorf("url/abc = %q, %v; want the legacy entry", dir, ok)
+	}
+	if got := storeIntegrity(storeDir, "url/abc"); got != "" {
+		t.Errorf("storeIntegrity of a legacy entry = %q, want empty", got)
+	}
+	for _, kept := range []string{"right@1.0.0.tmp", "notes.txt"} {
+		if _, err := os.Stat(filepath.Join(storeDir, kept)); err != nil {
+			t.Errorf("%s was removed", kept)
+		}
+	}
+
+	// A second run leaves the index alone.
+	if err := writeStoreIndex(storeDir, "left@1.0.0-beta.1", integrity); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrateStore(storeDir); err != nil {
+		t.Fatal(err)
+	}
+	if got := storeIndex(storeDir, "left@1.0.0-beta.1"); got != integrity {
+		t.Errorf("index rewritten by a second migration: %q", got)
+	}
+}
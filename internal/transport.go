@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// newRegistryClient builds the client all registry traffic goes through,
+// manifests and tarballs alike. There is no overall timeout, which would cut
+// off large tarballs; connecting and waiting for headers are bounded instead.
+func newRegistryClient(cfg npmConfig) (*http.Client, error) {
+	tlsConfig, err := registryTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	return &http.Client{
+		CheckRedirect: sameHostRedirect,
+		Transport: &http.Transport{
+			Proxy:                 registryProxy(cfg),
+			DialContext:           dialer.DialContext,
+			TLSClientConfig:       tlsConfig,
+			MaxIdleConns:          100,
+			MaxIdleConnsPerHost:   20,
+			IdleConnTimeout:       90 * time.Second,
+			ForceAttemptHTTP2:     true,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		},
+	}, nil
+}
+
+// registryProxy picks the proxy like npm: https-proxy, then proxy, then the
+// HTTPS_PROXY variable for https requests, and proxy then HTTP_PROXY for plain
+// http. Hosts matching noproxy or NO_PROXY, and loopback, go direct.
+func registryProxy(cfg npmConfig) func(*http.Request) (*url.URL, error) {
+	httpProxy := firstSetting(cfg["proxy"], os.Getenv("HTTP_PROXY"), os.Getenv("http_proxy"))
+	httpsProxy := firstSetting(cfg["https-proxy"], cfg["proxy"], os.Getenv("HTTPS_PROXY"), os.Getenv("https_proxy"))
+	noProxy := firstSetting(cfg["noproxy"], os.Getenv("NO_PROXY"), os.Getenv("no_proxy"))
+	return func(req *http.Request) (*url.URL, error) {
+		proxy := httpProxy
+		if req.URL.Scheme == "https" {
+			proxy = httpsProxy
+		}
+		if proxy == "" || bypassProxy(req.URL, noProxy) {
+			return nil, nil
+		}
+		if !strings.Contains(proxy, "://") {
+			proxy = "http://" + proxy
+		}
+		u, err := url.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %q: %w", proxy, err)
+		}
+		return u, nil
+	}
+}
+
+// firstSetting returns the first value that is set, .npmrc's "false" and
+// "null" counting as unset.
+func firstSetting(values ...string) string {
+	for _, value := range values {
+		if value != "" && value != "false" && value != "null" {
+			return value
+		}
+	}
+	return ""
+}
+
+// bypassProxy matches u against a NO_PROXY list: "*", hosts and domain
+// suffixes (with or without a leading dot), optionally with a port, and CIDR
+// ranges.
+func bypassProxy(u *url.URL, noProxy string) bool {
+	host := strings.ToLower(u.Hostname())
+	ip := net.ParseIP(host)
+	if host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return true
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	for _, entry := range strings.FieldsFunc(strings.ToLower(noProxy), func(r rune) bool { return r == ',' || r == ' ' }) {
+		if entry == "*" {
+			return true
+		}
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			if p != port {
+				continue
+			}
+			entry = h
+		}
+		entry = strings.TrimPrefix(strings.TrimPrefix(entry, "*"), ".")
+		if host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// registryTLSConfig applies strict-ssl and the certificate authorities of
+// cafile, ca and ca[]. As in Node those replace the system roots, while the
+// NODE_EXTRA_CA_CERTS file is added to them.
+func registryTLSConfig(cfg npmConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg["strict-ssl"] == "false"}
+	var certs []byte
+	if file := firstSetting(cfg["cafile"]); file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("reading cafile: %w", err)
+		}
+		certs = append(certs, data...)
+	}
+	for _, ca := range []string{cfg["ca"], cfg["ca[]"]} {
+		if ca = firstSetting(ca); ca != "" {
+			certs = append(certs, strings.ReplaceAll(ca, `\n`, "\n")+"\n"...)
+		}
+	}
+	if len(certs) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(certs) {
+			return nil, fmt.Errorf("no certificates found in the ca or cafile settings")
+		}
+		tlsConfig.RootCAs = pool
+		return tlsConfig, nil
+	}
+	if file := os.Getenv("NODE_EXTRA_CA_CERTS"); file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("reading NODE_EXTRA_CA_CERTS: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pool.AppendCertsFromPEM(data)
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,204 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Workspace is a package of a monorepo, found through the "workspaces" globs
+// of the root package.json. Dir is relative to the project root.
+type Workspace struct {
+	Dir     string
+	Package PackageJson
+}
+
+// key is the workspace's node in the graph, the same one a "link:" to its
+// directory would produce.
+func (w Workspace) key() string {
+	return PackageKey(w.Package.Name, w.spec())
+}
+
+func (w Workspace) spec() string {
+	return fileSpec{path: w.Dir, link: true}.String()
+}
+
+// workspacePatterns accepts both the npm form, an array of globs, and the
+// Yarn form, an object with a "packages" array.
+func workspacePatterns(pkgs PackageJson) []string {
+	list := pkgs.Workspaces
+	if obj, ok := list.(map[string]interface{}); ok {
+		list = obj["packages"]
+	}
+	items, _ := list.([]interface{})
+	var patterns []string
+	for _, item := range items {
+		if pattern, ok := item.(string); ok {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+// FindWorkspaces expands the workspace globs of pkgs under root. Patterns
+// starting with ! exclude directories matched by earlier ones.
+func FindWorkspaces(root string, pkgs PackageJson) ([]Workspace, error) {
+	patterns := workspacePatterns(pkgs)
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	var include, exclude []string
+	for _, pattern := range patterns {
+		pattern = strings.TrimSuffix(strings.TrimPrefix(path.Clean(filepath.ToSlash(pattern)), "./"), "/")
+		if negated, ok := strings.CutPrefix(pattern, "!"); ok {
+			exclude = append(exclude, negated)
+		} else {
+			include = append(include, pattern)
+		}
+	}
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, pattern := range include {
+		matches, err := expandWorkspaceGlob(root, "", strings.Split(pattern, "/"))
+		if err != nil {
+			return nil, err
+		}
+		for _, rel := range matches {
+			if seen[rel] || rel == "" || matchesAnyWorkspaceGlob(exclude, rel) {
+				continue
+			}
+			seen[rel] = true
+			if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(rel), "package.json")); err == nil {
+				dirs = append(dirs, rel)
+			}
+		}
+	}
+	sort.Strings(dirs)
+	var workspaces []Workspace
+	byName := make(map[string]string)
+	for _, dir := range dirs {
+		data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(dir), "package.json"))
+		if err != nil {
+			return nil, err
+		}
+		var ws PackageJson
+		if err := json.Unmarshal(data, &ws); err != nil {
+			return nil, fmt.Errorf("invalid %s/package.json: %w", dir, err)
+		}
+		if ws.Name == "" {
+			return nil, fmt.Errorf("workspace %s has no name in its package.json", dir)
+		}
+		if other, ok := byName[ws.Name]; ok {
+			return nil, fmt.Errorf("workspaces %s and %s are both named %s", other, dir, ws.Name)
+		}
+		byName[ws.Name] = dir
+		workspaces = append(workspaces, Workspace{Dir: dir, Package: ws})
+	}
+	return workspaces, nil
+}
+
+// expandWorkspaceGlob returns the directories below rel matching the pattern
+// segments, relative to root. Only "**" walks the tree; other segments are
+// globbed one directory at a time. node_modules and hidden directories are
+// never matched by a wildcard.
+func expandWorkspaceGlob(root, rel string, pattern []string) ([]string, error) {
+	if len(pattern) == 0 {
+		return []string{rel}, nil
+	}
+	if pattern[0] == "**" {
+		matches, err := expandWorkspaceGlob(root, rel, pattern[1:])
+		if err != nil {
+			return nil, err
+		}
+		entries, err := os.ReadDir(filepath.Join(root, filepath.FromSlash(rel)))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() || skipWorkspaceDir(entry.Name(), "**") {
+				continue
+			}
+			below, err := expandWorkspaceGlob(root, path.Join(rel, entry.Name()), pattern)
+			if err != nil {
+				return nil, err
+			}
+			matches = append(matches, below...)
+		}
+		return matches, nil
+	}
+	found, err := filepath.Glob(filepath.Join(root, filepath.FromSlash(rel), pattern[0]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid workspace pattern %q: %w", pattern[0], err)
+	}
+	var matches []string
+	for _, match := range found {
+		name := filepath.Base(match)
+		if info, err := os.Stat(match); err != nil || !info.IsDir() || skipWorkspaceDir(name, pattern[0]) {
+			continue
+		}
+		below, err := expandWorkspaceGlob(root, path.Join(rel, name), pattern[1:])
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, below...)
+	}
+	return matches, nil
+}
+
+// skipWorkspaceDir keeps wildcards out of node_modules and hidden
+// directories, which a segment can still name explicitly.
+func skipWorkspaceDir(name, segment string) bool {
+	if name == segment {
+		return false
+	}
+	return name == "node_modules" || strings.HasPrefix(name, ".")
+}
+
+func matchesAnyWorkspaceGlob(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if matchGlob(strings.Split(pattern, "/"), strings.Split(rel, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches path segments against pattern segments, where "**"
+// stands for any number of directories.
+func matchGlob(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchGlob(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+		return false
+	}
+	return matchGlob(pattern[1:], segments[1:])
+}
+
+// workspaceSpecifiers records what each workspace asks for, keyed by its
+// directory, so that editing a workspace's package.json invalidates the lock.
+func workspaceSpecifiers(workspaces []Workspace) map[string]map[string]string {
+	if len(workspaces) == 0 {
+		return nil
+	}
+	specs := make(map[string]map[string]string, len(workspaces))
+	for _, ws := range workspaces {
+		specs[ws.Dir] = rootSpecifiers(ws.Package)
+	}
+	return specs
+}
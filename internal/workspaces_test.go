@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindWorkspaces(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{
+		"packages/a",
+		"packages/c",
+		"packages/.hidden",
+		"packages/a/node_modules/dep",
+		"tools",
+		"tools/deep/x",
+		"tools/node_modules/y",
+		"tools/.cache/z",
+		"node_modules/packages/n",
+	} {
+		dir = filepath.Join(root, filepath.FromSlash(dir))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		manifest := `{"name": "` + filepath.Base(dir) + `", "version": "1.0.0"}`
+		if err := os.WriteFile(filepath.Join(dir, "package.json"), []byte(manifest), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A directory without a package.json is not a workspace.
+	if err := os.MkdirAll(filepath.Join(root, "packages", "b"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		workspaces interface{}
+		want       []string
+	}{
+		{"npm globs", []interface{}{"packages/*"}, []string{"packages/a", "packages/c"}},
+		{"exclusion", []interface{}{"./packages/*/", "!packages/c"}, []string{"packages/a"}},
+		{"double star", []interface{}{"tools/**"}, []string{"tools", "tools/deep/x"}},
+		{"explicit hidden directory", []interface{}{"packages/.hidden"}, []string{"packages/.hidden"}},
+		{"yarn object", map[string]interface{}{"packages": []interface{}{"packages/a", "packages/a"}}, []string{"packages/a"}},
+		{"no match", []interface{}{"apps/*"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workspaces, err := FindWorkspaces(root, PackageJson{Workspaces: tt.workspaces})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var dirs []string
+			for _, ws := range workspaces {
+				dirs = append(dirs, ws.Dir)
+			}
+			if !reflect.DeepEqual(dirs, tt.want) {
+				t.Errorf("workspaces = %v, want %v", dirs, tt.want)
+			}
+		})
+	}
+}
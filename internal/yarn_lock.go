@@ -0,0 +1,249 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const YarnLockfileName = "yarn.lock"
+
+// yarnEntry is one block of a yarn.lock, shared by every descriptor
+// (name@range) listed in its header.
+type yarnEntry struct {
+	Version              string            `yaml:"version"`
+	Resolved             string            `yaml:"resolved"`
+	Resolution           string            `yaml:"resolution"`
+	Integrity            string            `yaml:"integrity"`
+	Dependencies         map[string]string `yaml:"dependencies"`
+	OptionalDependencies map[string]string `yaml:"optionalDependencies"`
+	LinkType             string            `yaml:"linkType"`
+}
+
+func parseYarnLockfile(data []byte, pkgs PackageJson, _ []Workspace) (Resolved, error) {
+	if bytes.Contains(data, []byte("\n__metadata:")) || bytes.HasPrefix(data, []byte("__metadata:")) {
+		return parseYarnBerryLockfile(data)
+	}
+	return parseYarnClassicLockfile(data, pkgs)
+}
+
+// parseYarnClassicLockfile reads the indentation based v1 format:
+//
+//	"name@^1.0.0", name@^1.1.0:
+//	  version "1.2.0"
+//	  resolved "https://registry.yarnpkg.com/name/-/name-1.2.0.tgz#sha1"
+//	  dependencies:
+//	    other "^2.0.0"
+func parseYarnClassicLockfile(data []byte, pkgs PackageJson) (Resolved, error) {
+	entries := make(map[string]*yarnEntry)
+	var current *yarnEntry
+	var section map[string]string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		switch {
+		case indent == 0:
+			if !strings.HasSuffix(trimmed, ":") {
+				return Resolved{}, fmt.Errorf("%s:%d: expected an entry header", YarnLockfileName, lineNo)
+			}
+			current = &yarnEntry{}
+			section = nil
+			for _, descriptor := range strings.Split(strings.TrimSuffix(trimmed, ":"), ",") {
+				entries[unquoteYarn(strings.TrimSpace(descriptor))] = current
+			}
+		case current == nil:
+			return Resolved{}, fmt.Errorf("%s:%d: field outside of an entry", YarnLockfileName, lineNo)
+		case indent == 2 && strings.HasSuffix(trimmed, ":"):
+			section = make(map[string]string)
+			switch strings.TrimSuffix(trimmed, ":") {
+			case "dependencies":
+				current.Dependencies = section
+			case "optionalDependencies":
+				current.OptionalDependencies = section
+			}
+		case indent == 2:
+			section = nil
+			key, value := splitYarnField(trimmed)
+			switch key {
+			case "version":
+				current.Version = value
+			case "resolved":
+				current.Resolved = value
+			case "integrity":
+				current.Integrity = value
+			}
+		case section != nil:
+			key, value := splitYarnField(trimmed)
+			section[key] = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Resolved{}, err
+	}
+	resolved := newResolved()
+	keys := make(map[*yarnEntry]string)
+	for _, descriptor := range sortedKeys(entries) {
+		entry := entries[descriptor]
+		if _, ok := keys[entry]; ok || entry.Version == "" {
+			continue
+		}
+		name, spec := splitDescriptor(descriptor)
+		if target, _, ok := parseAlias(spec); ok {
+			name = target
+		}
+		tarball, integrity := entry.Resolved, entry.Integrity
+		key := PackageKey(name, entry.Version)
+		if source, ok := gitSource(entry.Resolved); ok {
+			// Keyed by source like the resolver keys git dependencies.
+			tarball, key = source, PackageKey(name, source)
+		} else if _, isGit := parseGitSpec(entry.Resolved); isGit {
+			// Not pinned to a commit, so left for the resolver.
+			continue
+		} else {
+			// The fragment of a registry or tarball URL is its sha1 shasum.
+			var shasum string
+			tarball, shasum, _ = strings.Cut(entry.Resolved, "#")
+			if integrity == "" && shasum != "" {
+				integrity = shasumToIntegrity(shasum)
+			}
+		}
+		keys[entry] = key
+		resolved.Packages[key] = Deps{
+			Name:         name,
+			Version:      entry.Version,
+			Tarball:      tarball,
+			Integrity:    integrity,
+			Dependencies: make(map[string]string),
+		}
+	}
+	for entry, key := range keys {
+		for _, deps := range []map[string]string{entry.Dependencies, entry.OptionalDependencies} {
+			for name, spec := range deps {
+				if target, ok := entries[name+"@"+spec]; ok {
+					resolved.Packages[key].Dependencies[name] = keys[target]
+				}
+			}
+		}
+	}
+	for name, spec := range rootSpecifiers(pkgs) {
+		if entry, ok := entries[name+"@"+spec]; ok {
+			resolved.Dependencies[name] = keys[entry]
+		}
+	}
+	return resolved, nil
+}
+
+// parseYarnBerryLockfile reads the YAML format of Yarn 2+. Berry does not
+// record tarball URLs or npm integrity, so tarballs are derived from the
+// registry and the root edges come from the project's workspace entry.
+func parseYarnBerryLockfile(data []byte) (Resolved, error) {
+	var doc map[string]yarnEntry
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return Resolved{}, fmt.Errorf("invalid %s: %w", YarnLockfileName, err)
+	}
+	entries := make(map[string]string)
+	resolved := newResolved()
+	var root *yarnEntry
+	for _, header := range sortedKeys(doc) {
+		if header == "__metadata" {
+			continue
+		}
+		entry := doc[header]
+		name, reference := splitDescriptor(entry.Resolution)
+		if strings.HasPrefix(reference, "workspace:") {
+			if reference == "workspace:." {
+				root = &entry
+			}
+			continue
+		}
+		if !strings.HasPrefix(reference, "npm:") {
+			continue
+		}
+		version := strings.TrimPrefix(reference, "npm:")
+		key := PackageKey(name, version)
+		for _, descriptor := range strings.Split(header, ",") {
+			entries[strings.TrimSpace(descriptor)] = key
+		}
+		if _, ok := resolved.Packages[key]; ok {
+			continue
+		}
+		resolved.Packages[key] = Deps{
+			Name:         name,
+			Version:      version,
+			Tarball:      registryTarballURL(name, version),
+			Dependencies: make(map[string]string),
+		}
+	}
+	link := func(edges map[string]string, deps map[string]string) {
+		for name, spec := range deps {
+			if !strings.Contains(spec, ":") {
+				spec = "npm:" + spec
+			}
+			if key, ok := entries[name+"@"+spec]; ok {
+				edges[name] = key
+			}
+		}
+	}
+	for _, header := range sortedKeys(doc) {
+		entry := doc[header]
+		key, ok := entries[strings.TrimSpace(strings.Split(header, ",")[0])]
+		if !ok {
+			continue
+		}
+		link(resolved.Packages[key].Dependencies, entry.Dependencies)
+		link(resolved.Packages[key].Dependencies, entry.OptionalDependencies)
+	}
+	if root != nil {
+		link(resolved.Dependencies, root.Dependencies)
+		link(resolved.Dependencies, root.OptionalDependencies)
+	}
+	return resolved, nil
+}
+
+func splitYarnField(field string) (string, string) {
+	key, value, _ := strings.Cut(field, " ")
+	return unquoteYarn(key), unquoteYarn(strings.TrimSpace(value))
+}
+
+func unquoteYarn(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// splitDescriptor splits "name@range", keeping the leading @ of scoped names.
+func splitDescriptor(descriptor string) (string, string) {
+	idx := strings.Index(descriptor[min(1, len(descriptor)):], "@")
+	if idx == -1 {
+		return descriptor, ""
+	}
+	idx++
+	return descriptor[:idx], descriptor[idx+1:]
+}
+
+func registryTarballURL(name, version string) string {
+	base := name[strings.LastIndex(name, "/")+1:]
+	return registryFor(name) + "/" + name + "/-/" + base + "-" + version + ".tgz"
+}
+
+func shasumToIntegrity(shasum string) string {
+	sum, err := hex.DecodeString(shasum)
+	if err != nil {
+		return ""
+	}
+	return "sha1-" + base64.StdEncoding.EncodeToString(sum)
+}
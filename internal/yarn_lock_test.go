@@ -0,0 +1,141 @@
+package internal
+
+import "testing"
+
+func TestParseYarnClassicLockfile(t *testing.T) {
+	useNpmrc(t, "")
+	const sha = "0123456789abcdef0123456789abcdef01234567"
+	data := []byte(`# THIS IS AN AUTOGENERATED FILE. DO NOT EDIT THIS FILE DIRECTLY.
+# yarn lockfile v1
+
+
+"@scope/util@^1.0.0", "@scope/util@^1.1.0":
+  version "1.2.0"
+  resolved "https://registry.yarnpkg.com/@scope/util/-/util-1.2.0.tgz#b1b2b3b4b5b6b7b8b9b0c1c2c3c4c5c6c7c8c9c0"
+  dependencies:
+    ms "^2.1.0"
+
+ms@^2.1.0:
+  version "2.1.3"
+  resolved "https://registry.yarnpkg.com/ms/-/ms-2.1.3.tgz#4d6f6e1f0b7b6fd8f3c9d5e2a1b0c9d8e7f6a5b4"
+  integrity sha512-6FlzubTLZG3J2a/NVCAleEhjzq5oxgHyaCU9yYXvcLsvoVaHJq/s5xXI6/XXP6tz7R9xAOtHnSO/tXtF3WRTlA==
+
+"mylib@git+https://github.com/org/mylib.git":
+  version "0.1.0"
+  resolved "git+https://github.com/org/mylib.git#` + sha + `"
+  dependencies:
+    ms "^2.1.0"
+
+"other@org/other":
+  version "3.0.0"
+  resolved "https://codeload.github.com/org/other/tar.gz/` + sha + `"
+
+"r17@npm:react@^17.0.0":
+  version "17.0.2"
+  resolved "https://registry.yarnpkg.com/react/-/react-17.0.2.tgz"
+`)
+	pkgs := PackageJson{Dependencies: map[string]string{
+		"@scope/util": "^1.1.0",
+		"mylib":       "git+https://github.com/org/mylib.git",
+		"other":       "org/other",
+		"r17":         "npm:react@^17.0.0",
+	}}
+	resolved, err := parseYarnLockfile(data, pkgs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mylib := "git+https://github.com/org/mylib.git#" + sha
+	other := "git+https://github.com/org/other.git#" + sha
+	wantRoot := map[string]string{
+		"@scope/util": "@scope/util@1.2.0",
+		"mylib":       PackageKey("mylib", mylib),
+		"other":       PackageKey("other", other),
+		"r17":         "react@17.0.2",
+	}
+	for name, key := range wantRoot {
+		if got := resolved.Dependencies[name]; got != key {
+			t.Errorf("root edge to %s = %q, want %q", name, got, key)
+		}
+	}
+	tests := []struct {
+		key       string
+		tarball   string
+		integrity string
+		ms        string
+	}{
+		{"@scope/util@1.2.0", "https://registry.yarnpkg.com/@scope/util/-/util-1.2.0.tgz", "sha1-sbKztLW2t7i5sMHCw8TFxsfIycA=", "ms@2.1.3"},
+		{"ms@2.1.3", "https://registry.yarnpkg.com/ms/-/ms-2.1.3.tgz", "sha512-6FlzubTLZG3J2a/NVCAleEhjzq5oxgHyaCU9yYXvcLsvoVaHJq/s5xXI6/XXP6tz7R9xAOtHnSO/tXtF3WRTlA==", ""},
+		{PackageKey("mylib", mylib), mylib, "", "ms@2.1.3"},
+		{PackageKey("other", other), other, "", ""},
+		{"react@17.0.2", "https://registry.yarnpkg.com/react/-/react-17.0.2.tgz", "", ""},
+	}
+	for _, tt := range tests {
+		deps, ok := resolved.Packages[tt.key]
+		if !ok {
+			t.Errorf("%s is not in the graph", tt.key)
+			continue
+		}
+		if deps.Tarball != tt.tarball || deps.Integrity != tt.integrity {
+			t.Errorf("%s = %q, %q; want %q, %q", tt.key, deps.Tarball, deps.Integrity, tt.tarball, tt.integrity)
+		}
+		if got := deps.Dependencies["ms"]; got != tt.ms {
+			t.Errorf("%s edge to ms = %q, want %q", tt.key, got, tt.ms)
+		}
+	}
+}
+
+func TestParseYarnBerryLockfile(t *testing.T) {
+	useNpmrc(t, "")
+	data := []byte(`__metadata:
+  version: 6
+  cacheKey: 8
+
+"debug@npm:^4.3.0":
+  version: 4.3.4
+  resolution: "debug@npm:4.3.4"
+  dependencies:
+    ms: 2.1.2
+  checksum: 3dbad3f94ea64f34431a9cbf0bafb61853eda57bff2880036153438f50fb5a84f27683ba0d8e5426bf41a8c6ff03879488120cf5b3a761e77953169c0600a708
+  languageName: node
+  linkType: hard
+
+"ms@npm:2.1.2":
+  version: 2.1.2
+  resolution: "ms@npm:2.1.2"
+  languageName: node
+  linkType: hard
+
+"ms@npm:^2.1.3":
+  version: 2.1.3
+  resolution: "ms@npm:2.1.3"
+  languageName: node
+  linkType: hard
+
+"root@workspace:.":
+  version: 0.0.0-use.local
+  resolution: "root@workspace:."
+  dependencies:
+    debug: ^4.3.0
+    ms: ^2.1.3
+  languageName: unknown
+  linkType: soft
+`)
+	resolved, err := parseYarnLockfile(data, PackageJson{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, key := range map[string]string{"debug": "debug@4.3.4", "ms": "ms@2.1.3"} {
+		if got := resolved.Dependencies[name]; got != key {
+			t.Errorf("root edge to %s = %q, want %q", name, got, key)
+		}
+	}
+	if got := resolved.Packages["debug@4.3.4"].Dependencies["ms"]; got != "ms@2.1.2" {
+		t.Errorf("debug edge to ms = %q, want ms@2.1.2", got)
+	}
+	if got, want := resolved.Packages["ms@2.1.2"].Tarball, registryTarballURL("ms", "2.1.2"); got != want {
+		t.Errorf("ms@2.1.2 tarball = %q, want %q", got, want)
+	}
+	if len(resolved.Packages) != 3 {
+		t.Errorf("graph has %d packages, want 3: %v", len(resolved.Packages), sortedKeys(resolved.Packages))
+	}
+}
@@ -43,68 +43,28 @@ func installDependenciesCmd() tea.Cmd {
 		if err != nil {
 			return errorMsg{err: err}
 		}
-		var toInstall []string
-		for name := range resolved {
-			if !internal.IsInstalled(name) {
-				toInstall = append(toInstall, name)
-			}
+		resolved, _, err = internal.ForPlatform(resolved, internal.HostPlatform())
+		if err != nil {
+			return errorMsg{err: err}
 		}
-		total := len(toInstall)
+		layout := internal.Hoist(resolved)
+		total, count := installLayout(resolved, layout)
 		if total == 0 {
 			return installCompleteMsg{
-				message: fmt.Sprintf("✅ All %d packages are already installed!", len(resolved)),
+				message: fmt.Sprintf("✅ All %d packages are already installed!", len(layout)),
 			}
 		}
-		const maxConcurrency = 50
-		semaphore := make(chan struct{}, maxConcurrency)
-		var wg sync.WaitGroup
-		results := make(chan installProgressMsg, total)
-		for _, name := range toInstall {
-			deps := resolved[name]
-			wg.Add(1)
-			go func(name string, deps internal.Deps) {
-				defer wg.Done()
-				semaphore <- struct{}{}
-				defer func() { <-semaphore }()
-				err := internal.Install(name, deps.Tarball)
-				if err != nil {
-					results <- installProgressMsg{
-						pkg:         name,
-						version:     deps.Version,
-						pkgProgress: 100,
-						pkgDone:     false,
-						pkgFailed:   true,
-					}
-					return
-				}
-				results <- installProgressMsg{
-					pkg:         name,
-					version:     deps.Version,
-					pkgProgress: 100,
-					pkgDone:     true,
-					pkgFailed:   false,
-				}
-			}(name, deps)
-		}
-		go func() {
-			wg.Wait()
-			close(results)
-		}()
-		count := 0
-		for range results {
-			count++
-		}
 		return installCompleteMsg{
 			message: fmt.Sprintf("✅ Successfully installed %d packages", count),
 		}
 	}
 }
-func installPackageCmd(name string, deps internal.Deps) tea.Cmd {
+func installPackageCmd(dir string, deps internal.Deps) tea.Cmd {
 	return func() tea.Msg {
-		err := internal.Install(name, deps.Tarball)
+		err := internal.Install(dir, deps)
 		if err != nil {
 			return installProgressMsg{
-				pkg:         name,
+				pkg:         dir,
 				version:     deps.Version,
 				pkgProgress: 100,
 				pkgDone:     false,
@@ -112,7 +72,7 @@ func installPackageCmd(name string, deps internal.Deps) tea.Cmd {
 			}
 		}
 		return installProgressMsg{
-			pkg:         name,
+			pkg:         dir,
 			version:     deps.Version,
 			pkgProgress: 100,
 			pkgDone:     true,
@@ -120,6 +80,41 @@ func installPackageCmd(name string, deps internal.Deps) tea.Cmd {
 		}
 	}
 }
+func installLayout(resolved internal.Resolved, layout internal.Layout) (int, int) {
+	const maxConcurrency = 50
+	semaphore := make(chan struct{}, maxConcurrency)
+	total := 0
+	count := 0
+	for _, level := range layout.ByDepth() {
+		var toInstall []string
+		for _, dir := range level {
+			if !internal.IsInstalled(dir, resolved.Packages[layout[dir]]) {
+				toInstall = append(toInstall, dir)
+			}
+		}
+		total += len(toInstall)
+		var wg sync.WaitGroup
+		results := make(chan installProgressMsg, len(toInstall))
+		for _, dir := range toInstall {
+			deps := resolved.Packages[layout[dir]]
+			wg.Add(1)
+			go func(dir string, deps internal.Deps) {
+				defer wg.Done()
+				semaphore <- struct{}{}
+				defer func() { <-semaphore }()
+				results <- installPackageCmd(dir, deps)().(installProgressMsg)
+			}(dir, deps)
+		}
+		go func() {
+			wg.Wait()
+			close(results)
+		}()
+		for range results {
+			count++
+		}
+	}
+	return total, count
+}
 func scanAndInstallCmd() tea.Cmd {
 	return func() tea.Msg {
 		wd, err := os.Getwd()
@@ -143,59 +138,19 @@ func scanAndInstallCmd() tea.Cmd {
 		if err != nil {
 			return errorMsg{err: err}
 		}
-		var toInstall []string
-		for name := range resolved {
-			if !internal.IsInstalled(name) {
-				toInstall = append(toInstall, name)
-			}
+		resolved, _, err = internal.ForPlatform(resolved, internal.HostPlatform())
+		if err != nil {
+			return errorMsg{err: err}
 		}
-		total := len(toInstall)
+		layout := internal.Hoist(resolved)
+		total, count := installLayout(resolved, layout)
 		if total == 0 {
 			return installCompleteMsg{
-				message: fmt.Sprintf("✅ Scanned and installed %d / %d package(s)", 0, len(resolved)),
+				message: fmt.Sprintf("✅ Scanned and installed %d / %d package(s)", 0, len(layout)),
 			}
 		}
-		const maxConcurrency = 50
-		semaphore := make(chan struct{}, maxConcurrency)
-		var wg sync.WaitGroup
-		results := make(chan installProgressMsg, total)
-		for _, name := range toInstall {
-			deps := resolved[name]
-			wg.Add(1)
-			go func(name string, deps internal.Deps) {
-				defer wg.Done()
-				semaphore <- struct{}{}
-				defer func() { <-semaphore }()
-				err := internal.Install(name, deps.Tarball)
-				if err != nil {
-					results <- installProgressMsg{
-						pkg:         name,
-						version:     deps.Version,
-						pkgProgress: 100,
-						pkgDone:     false,
-						pkgFailed:   true,
-					}
-					return
-				}
-				results <- installProgressMsg{
-					pkg:         name,
-					version:     deps.Version,
-					pkgProgress: 100,
-					pkgDone:     true,
-					pkgFailed:   false,
-				}
-			}(name, deps)
-		}
-		go func() {
-			wg.Wait()
-			close(results)
-		}()
-		count := 0
-		for range results {
-			count++
-		}
 		return installCompleteMsg{
-			message: fmt.Sprintf("✅ Scanned and installed %d / %d package(s)", count, len(resolved)),
+			message: fmt.Sprintf("✅ Scanned and installed %d / %d package(s)", count, len(layout)),
 		}
 	}
 }